@@ -0,0 +1,60 @@
+#!/bin/bash
+
+set -e
+
+function usage() {
+    echo
+    echo "Remove files from the rootpath along with any directories left empty."
+    echo "This is used after copy_in/copy_out directives against an existing global lustre"
+    echo "filesystem to remove the files created by the test."
+    echo
+    echo "Syntax: cleanup.sh ROOT_DIR FILE [FILE...]"
+    echo
+    echo "arguments:"
+    echo "ROOT_DIR      root path directory; assume this is the global lustre"
+    echo "              mount root (e.g. /lus/global). This is never removed."
+    echo "FILE          full path of a file to remove. An asterisk can be used to"
+    echo "              represent index mount directories."
+    echo
+    echo "examples:"
+    echo "cleanup.sh /lus/global /lus/global/testuser/test.in /lus/global/testuser/test.out"
+    echo "cleanup.sh /lus/global /lus/global/testuser/test.in '/lus/global/testuser/*/test.out'"
+    echo
+}
+
+ROOT_DIR=${1%/}
+shift || true
+
+if [[ -z "$ROOT_DIR" ]]; then
+    usage
+    exit 1
+elif [[ $# -eq 0 ]]; then
+    usage
+    exit 1
+fi
+
+shopt -s nullglob
+
+for FILE in "$@"; do
+    if [[ "$FILE" != "$ROOT_DIR"/* ]]; then
+        echo "$FILE is not in $ROOT_DIR"
+        exit 1
+    fi
+
+    # Expand the asterisk for any index mount directories
+    for MATCH in $FILE; do
+        rm -fv "$MATCH"
+
+        # Remove the directories that are now empty, up to the root directory
+        DIR=$(dirname "$MATCH")
+        while [[ "$DIR" != "$ROOT_DIR" && "$DIR" == "$ROOT_DIR"/* ]]; do
+            rmdir "$DIR" 2>/dev/null || break
+            echo "removed directory '$DIR'"
+            DIR=$(dirname "$DIR")
+        done
+    done
+done
+
+set +e
+
+exit 0
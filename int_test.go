@@ -21,13 +21,11 @@ package test
 
 import (
 	"fmt"
-	"time"
 
 	. "github.com/NearNodeFlash/nnf-integration-test/internal"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"go.openly.dev/pointy"
 
 	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
 )
@@ -46,15 +44,12 @@ var (
 		dwsv1alpha7.StateSetup,
 		dwsv1alpha7.StateTeardown,
 	}
-
-	// User/Group IDs for tests that require permissions. These reference the
-	// configurable values in internal/utils.go which can be overridden via
-	// NNF_USER_ID and NNF_GROUP_ID environment variables. They must correspond
-	// to a real user on the system's Rabbit/compute nodes.
-	userID  = TestUserID
-	groupID = TestGroupID
 )
 
+// Test cases are defined in catalog files (see catalogs/default.yaml). Catalogs are selected with
+// the `-catalog` flag or the NNF_TEST_CATALOG environment variable, and default to the shared
+// catalogs directory. Test cases that cannot be expressed in a catalog can be added here and
+// run alongside the catalog tests.
 var tests = []*T{
 	// Examples:
 	//
@@ -76,184 +71,88 @@ var tests = []*T{
 	//      MakeTest("XFS", "#DW jobdw type=xfs name=xfs capacity=50GB"),
 	//      20,
 	//   ),
-
-	MakeTest("XFS", "#DW jobdw type=xfs name=xfs capacity=50GB").WithLabels(Simple),
-	MakeTest("GFS2", "#DW jobdw type=gfs2 name=gfs2 capacity=50GB").WithLabels(Simple),
-	MakeTest("Lustre", "#DW jobdw type=lustre name=lustre capacity=50GB").WithLabels(Simple),
-	MakeTest("Raw", "#DW jobdw type=raw name=raw capacity=50GB").WithLabels(Simple),
-
-	// External Computes
-	MakeTest("Lustre External", "#DW jobdw type=lustre name=lustre capacity=50GB").WithExternalComputes().WithLabels(ExternalLustre),
-
-	// GFS2 Fence
-	MakeTest("GFS2 Fence", "#DW jobdw type=gfs2 name=gfs2-fence capacity=50GB").WithLabels(GFS2Fence).
-		RequiresHardware().
-		DelayInState(dwsv1alpha7.StateDataIn, 15*time.Second).  // start pacemaker
-		DelayInState(dwsv1alpha7.StatePreRun, 60*time.Second).  // fence node(s)
-		DelayInState(dwsv1alpha7.StateDataOut, 15*time.Second), // stop pacemaker on surviving node(s)
-
-	// Storage Profiles
-	MakeTest("XFS with Storage Profile",
-		"#DW jobdw type=xfs name=xfs-storage-profile capacity=50GB profile=my-xfs-storage-profile").
-		WithStorageProfile(),
-	MakeTest("GFS2 with Storage Profile",
-		"#DW jobdw type=gfs2 name=gfs2-storage-profile capacity=50GB profile=my-gfs2-storage-profile").
-		WithStorageProfile(),
-	// WithStorageProfile().DelayInState(dwsv1alpha7.StateDataIn, 15*time.Second).DelayInState(dwsv1alpha7.StateDataOut, 15*time.Second).Focused(),    // Useful for debugging
-	// WithStorageProfile().DelayInState(dwsv1alpha7.StateDataIn, 15*time.Second).StopAfter(dwsv1alpha7.StatePreRun).Focused(),
-	MakeTest("XFS with Storage Profile and LV Create",
-		"#DW jobdw type=xfs name=xfs-storage-profile capacity=14TB profile=my-xfs-storage-profile").
-		WithStorageProfileLvCreate("--zero n --activate y --type raid5 --nosync --extents $PERCENT_VG --stripes $DEVICE_NUM-1 --stripesize=64KiB --name $LV_NAME $VG_NAME").
-		WithLabels("high-capacity"),
-
-	// Persistent
-	MakeTest("Persistent Lustre",
-		"#DW create_persistent type=lustre name=persistent-lustre capacity=50GB").
-		AndCleanupPersistentInstance().
-		Serialized(),
-
-	// Data Movement
-	MakeTest("XFS with Data Movement",
-		"#DW jobdw type=xfs name=xfs-data-movement capacity=50GB",
-		"#DW copy_in source=/lus/zenith/testuser/test.in destination=$DW_JOB_xfs-data-movement/",
-		"#DW copy_out source=$DW_JOB_xfs-data-movement/test.in destination=/lus/zenith/testuser/test.out").
-		WithPersistentLustre("xfs-data-movement-lustre-instance").
-		WithGlobalLustreFromPersistentLustre("zenith", []string{"default"}).
-		WithPermissions(userID, groupID).
-		WithLabels("dm").
-		HardwareRequired(),
-	MakeTest("GFS2 with Data Movement",
-		"#DW jobdw type=gfs2 name=gfs2-data-movement capacity=50GB",
-		"#DW copy_in source=/lus/kelso/testuser/test.in destination=$DW_JOB_gfs2-data-movement/",
-		"#DW copy_out profile=no-xattr source=$DW_JOB_gfs2-data-movement/test.in destination=/lus/kelso/testuser/test.out").
-		WithPersistentLustre("gfs2-data-movement-lustre-instance").
-		WithGlobalLustreFromPersistentLustre("kelso", []string{"default"}).
-		WithPermissions(userID, groupID).
-		WithLabels("dm").
-		HardwareRequired(),
-	MakeTest("Lustre with Data Movement",
-		"#DW jobdw type=lustre name=lustre-data-movement capacity=50GB profile=lustre-dm",
-		"#DW copy_in source=/lus/flame/testuser/test.in destination=$DW_JOB_lustre-data-movement/",
-		"#DW copy_out source=$DW_JOB_lustre-data-movement/test.in destination=/lus/flame/testuser/test.out").
-		WithPersistentLustre("lustre-data-movement-lustre-instance").
-		WithGlobalLustreFromPersistentLustre("flame", []string{"default"}).
-		WithStorageProfileExternalMGSFromPersistentLustre().
-		WithPermissions(userID, groupID).
-		WithLabels("dm").
-		HardwareRequired(),
-
-	// Containers - MPI
-	MakeTest("GFS2 with MPI Containers",
-		"#DW jobdw type=gfs2 name=gfs2-with-containers-mpi capacity=100GB",
-		"#DW container name=gfs2-with-containers-mpi profile=example-mpi "+
-			"DW_JOB_foo_local_storage=gfs2-with-containers-mpi").
-		WithPermissions(userID, groupID).WithLabels("mpi"),
-	MakeTest("Lustre with MPI Containers",
-		"#DW jobdw type=lustre name=lustre-with-containers-mpi capacity=100GB",
-		"#DW container name=lustre-with-containers-mpi profile=example-mpi "+
-			"DW_JOB_foo_local_storage=lustre-with-containers-mpi").
-		WithPermissions(userID, groupID).WithLabels("mpi", "lustre-csimount"),
-	MakeTest("GFS2 and Global Lustre with MPI Containers",
-		"#DW jobdw type=gfs2 name=gfs2-and-global-with-containers-mpi capacity=100GB",
-		"#DW container name=gfs2-and-global-with-containers-mpi profile=example-mpi "+
-			"DW_JOB_foo_local_storage=gfs2-and-global-with-containers-mpi "+
-			"DW_GLOBAL_foo_global_lustre=/lus/polly").
-		WithPermissions(userID, groupID).
-		WithPersistentLustre("gfs2-and-global-with-containers-polly").
-		WithGlobalLustreFromPersistentLustre("polly", []string{"default"}).
-		WithLabels("mpi", "global-lustre"),
-
-	// Containers - Copy Offload API
-	MakeTest("GFS2 with Copy Offload",
-		"#DW jobdw type=gfs2 name=project1 capacity=50GB requires=copy-offload",
-		"#DW container name=copyoff-container profile=copy-offload-kind DW_JOB_my_storage=project1").
-		WithPermissions(userID, groupID).WithLabels("mpi", "copy-offload").
-		WithContainerProfile("copy-offload-default", &ContainerProfileOptions{NoStorage: true}),
-
-	// Containers - MPI failures
-	MakeTest("PreRun timeout on MPI containers",
-		"#DW container name=prerun-timeout-mpi profile=example-mpi-prerun-timeout").
-		WithPermissions(userID, groupID).WithLabels("mpi", "timeout").
-		WithContainerProfile("example-mpi", &ContainerProfileOptions{PrerunTimeoutSeconds: pointy.Int(1), NoStorage: true}).
-		ExpectError(dwsv1alpha7.StatePreRun),
-	MakeTest("PostRun timeout on MPI containers",
-		"#DW container name=postrun-timeout-mpi profile=example-mpi-postrun-timeout").
-		WithPermissions(userID, groupID).WithLabels("mpi", "timeout").
-		WithContainerProfile("example-mpi-webserver", &ContainerProfileOptions{PostrunTimeoutSeconds: pointy.Int(1), NoStorage: true}).
-		ExpectError(dwsv1alpha7.StatePostRun),
-	MakeTest("Non-zero exit on MPI containers",
-		"#DW container name=mpi-container-fail profile=example-mpi-fail-noretry").
-		WithPermissions(userID, groupID).WithLabels("mpi", "fail").
-		WithContainerProfile("example-mpi-fail", &ContainerProfileOptions{RetryLimit: pointy.Int(0)}).
-		ExpectError(dwsv1alpha7.StatePostRun),
-
-	// Containers - Non-MPI
-	MakeTest("GFS2 with Containers",
-		"#DW jobdw type=gfs2 name=gfs2-with-containers capacity=100GB",
-		"#DW container name=gfs2-with-containers profile=example-success DW_JOB_foo_local_storage=gfs2-with-containers").
-		WithPermissions(userID, groupID).WithLabels("non-mpi"),
-	MakeTest("GFS2 and Global Lustre with Containers",
-		"#DW jobdw type=gfs2 name=gfs2-and-global-with-containers capacity=100GB",
-		"#DW container name=gfs2-and-global-with-containers profile=example-success "+
-			"DW_JOB_foo_local_storage=gfs2-and-global-with-containers "+
-			"DW_GLOBAL_foo_global_lustre=/lus/cherokee").
-		WithPermissions(userID, groupID).
-		WithPersistentLustre("gfs2-and-global-with-containers-cherokee").
-		WithGlobalLustreFromPersistentLustre("cherokee", []string{"default"}).
-		WithLabels("non-mpi", "global-lustre"),
-
-	// Containers - Non-MPI failures
-	MakeTest("PreRun timeout on non-MPI containers",
-		"#DW container name=prerun-timeout profile=example-prerun-timeout").
-		WithPermissions(userID, groupID).WithLabels("non-mpi", "timeout").
-		WithContainerProfile("example-forever", &ContainerProfileOptions{PrerunTimeoutSeconds: pointy.Int(1), NoStorage: true}).
-		ExpectError(dwsv1alpha7.StatePreRun),
-	MakeTest("PostRun timeout on non-MPI containers",
-		"#DW container name=postrun-timeout profile=example-postrun-timeout").
-		WithPermissions(userID, groupID).WithLabels("non-mpi", "timeout").
-		WithContainerProfile("example-forever", &ContainerProfileOptions{PostrunTimeoutSeconds: pointy.Int(1), NoStorage: true}).
-		ExpectError(dwsv1alpha7.StatePostRun),
-	MakeTest("Non-zero exit on non-MPI containers",
-		"#DW container name=container-fail profile=example-fail-noretry").
-		WithPermissions(userID, groupID).WithLabels("non-mpi", "fail").
-		WithContainerProfile("example-fail", &ContainerProfileOptions{RetryLimit: pointy.Int(0)}).
-		ExpectError(dwsv1alpha7.StatePostRun),
-
-	// Containers - Unsupported Filesystems. XFS is not supported for containers.
-	MakeTest("XFS with Containers",
-		"#DW jobdw type=xfs name=xfs-with-containers capacity=100GB",
-		"#DW container name=xfs-with-containers profile=example-success DW_JOB_foo_local_storage=xfs-with-containers").
-		ExpectError(dwsv1alpha7.StateProposal).WithLabels("unsupported-fs"),
-	MakeTest("Raw with Containers",
-		"#DW jobdw type=raw name=raw-with-containers capacity=100GB",
-		"#DW container name=raw-with-containers profile=example-success DW_JOB_foo_local_storage=raw-with-containers").
-		WithPermissions(userID, groupID).WithLabels("non-mpi"),
-
-	// Containers - Multiple Storages
-	MakeTest("GFS2 and Lustre with Containers",
-		"#DW jobdw name=containers-local-storage type=gfs2 capacity=100GB",
-		"#DW persistentdw name=containers-persistent-storage",
-		"#DW container name=gfs2-lustre-with-containers profile=example-success DW_JOB_foo_local_storage=containers-local-storage DW_PERSISTENT_foo_persistent_storage=containers-persistent-storage").
-		WithPersistentLustre("containers-persistent-storage").
-		WithPermissions(userID, groupID).
-		WithLabels("multi-storage"),
-	MakeTest("GFS2 and Lustre with Containers MPI",
-		"#DW jobdw name=containers-local-storage-mpi type=gfs2 capacity=100GB",
-		"#DW persistentdw name=containers-persistent-storage-mpi",
-		"#DW container name=gfs2-lustre-with-containers-mpi profile=example-mpi DW_JOB_foo_local_storage=containers-local-storage-mpi DW_PERSISTENT_foo_persistent_storage=containers-persistent-storage-mpi").
-		WithPersistentLustre("containers-persistent-storage-mpi").
-		WithPermissions(userID, groupID).
-		WithLabels("multi-storage"),
-
-	// External MGS
-	MakeTest("Lustre with MGS pool",
-		"#DW jobdw name=external-mgs-pool type=lustre capacity=100GB profile=example-external-mgs").
-		WithMgsPool("lustre-mgs-pool", 1).WithStorageProfileExternalMGS("pool:lustre-mgs-pool"),
+	//
+	// Run a hook at an exact point in the workflow's lifecycle, e.g. to fence a node once PreRun
+	// is achieved. BeforeState() hooks run before the workflow advances to the state.
+	//   MakeTest("GFS2 Hook", "#DW ...").AfterState(dwsv1alpha7.StatePreRun,
+	//      func(ctx context.Context, k8sClient client.Client, t *T, workflow *dwsv1alpha7.Workflow) { ... }),
+	//
+	// Expect an error in PreRun, and that it is the container timeout reported by the nnf driver
+	//   MakeTest("Expect Error", "#DW ...").ExpectError(dwsv1alpha7.StatePreRun).WithErrorMessage("(?i)timeout").WithErrorDriver("nnf"),
+	//
+	// Expect the DWS webhook to reject going backwards from PreRun to Setup, and advancing to
+	// DataIn before Setup is Ready.
+	//   MakeTest("Illegal Transitions", "#DW ...").
+	//      ExpectTransitionRejected(dwsv1alpha7.StatePreRun, dwsv1alpha7.StateSetup).
+	//      ExpectTransitionRejected(dwsv1alpha7.StateSetup, dwsv1alpha7.StateDataIn),
+	//
+	// Expect the workflow to be rejected on creation because the capacity has no valid units
+	//   MakeTest("Bad Capacity", "#DW jobdw type=xfs name=bad-capacity capacity=50XB").ExpectCreateRejected("(?i)capacity"),
+	//
+	// Run two workflows at once that need most of the capacity of the same Rabbit; only one may
+	// win Setup. See internal/scenario.go.
+	//   MakeScenario("Capacity Contention", MakeTest("Large 1", "#DW ...").WithRabbits("rabbit-node-1"), MakeTest("Large 2", "#DW ...").WithRabbits("rabbit-node-1")).
+	//      Serialized().ExpectWinners(dwsv1alpha7.StateSetup, 1),
+	//
+	// Soak a test for 8 hours, continuing after a failed iteration. The summary reports the failure
+	// rate and the latency percentiles of each state. All selected tests can be soaked with the
+	// `-soak` flag instead (e.g. `ginkgo run --label-filter=simple . -- -soak=100`). A soak duration
+	// and its last iteration must fit within the Ginkgo suite timeout, so run this one with
+	// `ginkgo run --timeout=9h`.
+	//   MakeTest("XFS Soak", "#DW jobdw type=xfs name=xfs-soak capacity=50GB").Soak(SoakPolicy{Duration: 8 * time.Hour, ContinueOnFailure: true}),
+	//
+	// Run a test on a subset of the system; two computes and the Rabbits they are attached to.
+	//   MakeTest("XFS Subset", "#DW jobdw type=xfs name=xfs-subset capacity=50GB").WithComputeCount(2),
+	//
+	// Abort a test after Setup by going straight to Teardown, and verify nothing is left behind.
+	// AbortInEachState(t) or Across(AbortDimension()) in a matrix generate the abort-in-each-state
+	// variants.
+	//   MakeTest("XFS Abort", "#DW jobdw type=xfs name=xfs-abort capacity=50GB").AbortAfter(dwsv1alpha7.StateSetup),
+	//
+	// Build the directives with the typed directive builders rather than raw strings. Invalid
+	// arguments are reported with the test name. See internal/directives.go for cross-references.
+	//   MakeTestFromDirectives("XFS Builder", JobDW("xfs", "xfs-builder", "50GB")),
 }
 
+// Matrix tests expand a template test over one or more dimensions and are appended to the list of
+// tests. For example, to run each file system type with and without a storage profile, with each
+// test labeled by its dimension values (e.g. `type-xfs`, `profile-storage-profile`):
+//
+//	var tests = append([]*T{...},
+//		MakeMatrix("Matrix", "#DW jobdw name=matrix capacity=50GB").
+//			Across(Arg("type", "xfs", "gfs2", "lustre", "raw")).
+//			Across(Variants("profile",
+//				MatrixValue{Name: "default"},
+//				MatrixValue{Name: "storage-profile", Args: map[string]string{"profile": "matrix-profile"}, Apply: (*T).WithStorageProfile},
+//			)).
+//			Tests()...,
+//	)
+
+// The -soak flag soaks every selected test, one after another, so a duration soak needs the
+// duration times the number of specs from the suite timeout. Scenarios, which are not soaked, are
+// counted as well, making this an overestimate.
+var _ = ReportBeforeSuite(func(report Report) {
+	soakPolicy, err := ParseSoakPolicy(soak)
+	if err != nil || soakPolicy == nil {
+		return
+	}
+
+	Expect(soakPolicy.SoaksFitTimeout(report.PreRunStats.SpecsThatWillRun)).To(Succeed(), fmt.Sprintf("-soak=%s", soak))
+})
+
 var _ = Describe("NNF Integration Test", func() {
 
-	iterator := TestIterator(tests)
+	catalogTests, err := LoadCatalogs(CatalogPaths(catalog)...)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load test catalog: %v", err))
+	}
+
+	soakPolicy, err := ParseSoakPolicy(soak)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	iterator := TestIterator(append(catalogTests, tests...))
 	for t := iterator.Next(); t != nil; t = iterator.Next() {
 
 		// Note that you must assign a copy of the loop variable to a local variable - otherwise
@@ -262,8 +161,27 @@ var _ = Describe("NNF Integration Test", func() {
 		// the loop variable.
 		t := t
 
+		if soakPolicy != nil && !t.IsSoak() && !t.IsScenario() {
+			t.Soak(*soakPolicy)
+		}
+
 		Describe(t.Name(), append(t.Args(), func() {
 
+			// Soak tests run each iteration from Prepare() through Cleanup() themselves, and report the
+			// events and controller logs of a failed iteration
+			if t.IsSoak() {
+				It("Soaks", func() { t.RunSoak(ctx, k8sClient) })
+				return
+			}
+
+			// Record the events of the workflow and its children for the duration of the spec. This
+			// is registered first so the recorder stops after the workflow is deleted.
+			var timeline EventTimeline
+			BeforeEach(func() {
+				events := t.RecordEvents(ctx, k8sClient)
+				DeferCleanup(func() { timeline = events.Stop(ctx) })
+			})
+
 			// Prepare any necessary test conditions prior to creating the workflow
 			BeforeEach(func() {
 				Expect(t.Prepare(ctx, k8sClient)).To(Succeed())
@@ -274,6 +192,18 @@ var _ = Describe("NNF Integration Test", func() {
 			BeforeEach(func() {
 				workflow := t.Workflow()
 
+				// Tests that expect the workflow to be rejected do not execute the states
+				if t.ExpectsCreateRejected() {
+					By(fmt.Sprintf("Verifying workflow '%s' is rejected", workflow.Name))
+					t.VerifyCreateRejected(ctx, k8sClient)
+					return
+				}
+
+				// Scenarios create the workflows of their tests as they execute
+				if t.IsScenario() {
+					return
+				}
+
 				By(fmt.Sprintf("Creating workflow '%s'", workflow.Name))
 				Expect(k8sClient.Create(ctx, workflow)).To(Succeed())
 
@@ -282,7 +212,7 @@ var _ = Describe("NNF Integration Test", func() {
 						// TODO: Ginkgo's `--fail-fast` option still seems to execute DeferCleanup() calls
 						//       See if this is by design or if we might need to move this to an AfterEach()
 						if !context.SpecReport().Failed() {
-							t.AdvanceStateAndWaitForReady(ctx, k8sClient, workflow, dwsv1alpha7.StateTeardown)
+							t.EnsureTeardown(ctx, k8sClient, workflow)
 
 							Expect(k8sClient.Delete(ctx, workflow)).To(Succeed())
 						}
@@ -292,9 +222,17 @@ var _ = Describe("NNF Integration Test", func() {
 
 			// Report additional workflow data for each failed test
 			ReportAfterEach(func(report SpecReport) {
+				t.ReportEvents(ctx, timeline, report.Failed())
+
 				if report.Failed() {
 					workflow := t.Workflow()
-					AddReportEntry(fmt.Sprintf("Workflow '%s' Failed", workflow.Name), workflow.Status)
+					t.ReportWorkflowStatus(ctx, k8sClient)
+
+					// Collect the workflow and every related object for triage
+					t.ReportDiagnostics(ctx, k8sClient)
+
+					// Include the controller logs for the duration of the spec
+					t.ReportControllerLogs(ctx, k8sClient, report.StartTime, report.EndTime)
 
 					// Include container pod logs for container tests to aid diagnosis
 					if t.HasContainerDirective() {
@@ -0,0 +1,211 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+// BaselineEnvVar selects the baseline file when the `-baseline` flag is not provided
+const BaselineEnvVar = "NNF_TEST_BASELINE"
+
+// BaselineStates are the states compared against the baseline by default
+var BaselineStates = []dwsv1alpha7.WorkflowState{
+	dwsv1alpha7.StateSetup,
+	dwsv1alpha7.StatePreRun,
+	dwsv1alpha7.StateTeardown,
+}
+
+// Baseline is the expected latency of each state of each test on a system. Baselines are kept one
+// file per system, as the latencies of one system say little about another. For example,
+//
+//	system: rabbit-tds
+//	tests:
+//	  Lustre:
+//	    Setup: 40s
+//	    PreRun: 12s
+//	    Teardown: 35s
+type Baseline struct {
+	System string                                                   `json:"system,omitempty"`
+	Tests  map[string]map[dwsv1alpha7.WorkflowState]metav1.Duration `json:"tests"`
+}
+
+// Regression is a state of a test that took longer than its baseline by more than the threshold
+type Regression struct {
+	Test     string
+	State    dwsv1alpha7.WorkflowState
+	Baseline time.Duration
+	Actual   time.Duration
+}
+
+func (r Regression) String() string {
+	increase := 100 * float64(r.Actual-r.Baseline) / float64(r.Baseline)
+	return fmt.Sprintf("%s: %s took %s, baseline %s (+%.0f%%)", r.Test, r.State,
+		r.Actual.Round(time.Second), r.Baseline.Round(time.Second), increase)
+}
+
+// Latency returns the time the workflow took to achieve the state, as reported by DWS, or the
+// duration of the state's handler if DWS did not report the time.
+func (timing *StateTiming) Latency() time.Duration {
+	if timing.Elapsed != 0 {
+		return timing.Elapsed
+	}
+
+	return timing.Duration
+}
+
+// latencies returns the latency of each state in the timings. A state that was executed more than
+// once uses the longest latency.
+func (timings StateTimings) latencies() map[dwsv1alpha7.WorkflowState]time.Duration {
+	latencies := make(map[dwsv1alpha7.WorkflowState]time.Duration)
+	for index := range timings {
+		latencies[timings[index].State] = max(latencies[timings[index].State], timings[index].Latency())
+	}
+
+	return latencies
+}
+
+// ReadBaseline reads the baseline file. A file that does not exist is an empty baseline.
+func ReadBaseline(file string) (*Baseline, error) {
+	baseline := &Baseline{Tests: make(map[string]map[dwsv1alpha7.WorkflowState]metav1.Duration)}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return baseline, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.UnmarshalStrict(data, baseline); err != nil {
+		return nil, fmt.Errorf("baseline '%s': %w", file, err)
+	}
+
+	if baseline.Tests == nil {
+		baseline.Tests = make(map[string]map[dwsv1alpha7.WorkflowState]metav1.Duration)
+	}
+
+	return baseline, nil
+}
+
+// CheckSystem returns an error if the baseline is for a system other than this one. A baseline
+// without a system, such as a new file, matches any system.
+func (b *Baseline) CheckSystem(system string) error {
+	if b.System != "" && b.System != system {
+		return fmt.Errorf("baseline is for system '%s', not '%s'; use the baseline file of this system", b.System, system)
+	}
+
+	return nil
+}
+
+// WriteBaseline writes the baseline file
+func WriteBaseline(file string, baseline *Baseline) error {
+	data, err := yaml.Marshal(baseline)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, data, 0644)
+}
+
+// Update sets the baseline of every passed test in the results to its latencies in the compared
+// states. Tests that are not in the results keep their existing baseline.
+func (b *Baseline) Update(results *Results, states []dwsv1alpha7.WorkflowState) {
+	for _, result := range results.Tests {
+		if !result.Passed() {
+			continue
+		}
+
+		latencies := result.Timings.latencies()
+
+		baseline := make(map[dwsv1alpha7.WorkflowState]metav1.Duration)
+		for _, state := range states {
+			if latency, found := latencies[state]; found {
+				baseline[state] = metav1.Duration{Duration: latency.Round(time.Second)}
+			}
+		}
+
+		if len(baseline) != 0 {
+			b.Tests[result.Name] = baseline
+		}
+	}
+}
+
+// Compare returns the states of the passed tests in the results that took longer than their
+// baseline by more than the threshold, a percentage (e.g. 25 for 25%). Tests and states without a
+// baseline are not compared.
+func (b *Baseline) Compare(results *Results, states []dwsv1alpha7.WorkflowState, threshold float64) []Regression {
+	regressions := make([]Regression, 0)
+
+	for _, result := range results.Tests {
+		baseline, found := b.Tests[result.Name]
+		if !found || !result.Passed() {
+			continue
+		}
+
+		latencies := result.Timings.latencies()
+		for _, state := range states {
+			expected, found := baseline[state]
+			actual, executed := latencies[state]
+			if !found || !executed || expected.Duration <= 0 {
+				continue
+			}
+
+			if float64(actual) > float64(expected.Duration)*(1+threshold/100) {
+				regressions = append(regressions, Regression{
+					Test:     result.Name,
+					State:    state,
+					Baseline: expected.Duration,
+					Actual:   actual,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(regressions, func(i, j int) bool { return regressions[i].Test < regressions[j].Test })
+
+	return regressions
+}
+
+// RegressionSummary formats the regressions for printing
+func RegressionSummary(regressions []Regression, threshold float64) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%d state(s) regressed by more than %.0f%% against the baseline:\n", len(regressions), threshold)
+	for _, regression := range regressions {
+		fmt.Fprintf(b, "  %s\n", regression)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+func makeResults(setup, teardown time.Duration, passed bool) *Results {
+	state := "passed"
+	if !passed {
+		state = "failed"
+	}
+
+	return &Results{
+		Tests: []TestResult{{
+			Name:  "Lustre",
+			State: state,
+			Timings: StateTimings{
+				{State: dwsv1alpha7.StateProposal, Elapsed: time.Second},
+				{State: dwsv1alpha7.StateSetup, Elapsed: setup},
+				{State: dwsv1alpha7.StateTeardown, Duration: teardown},
+			},
+		}},
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "baselines", "rabbit-tds.yaml")
+
+	// A missing baseline is empty, and is created by updating it
+	baseline, err := ReadBaseline(file)
+	if err != nil || len(baseline.Tests) != 0 {
+		t.Fatalf("unexpected baseline %+v: %v", baseline, err)
+	}
+
+	baseline.System = "rabbit-tds"
+	baseline.Update(makeResults(40*time.Second, 30*time.Second, true), BaselineStates)
+	if err := WriteBaseline(file, baseline); err != nil {
+		t.Fatal(err)
+	}
+
+	baseline, err = ReadBaseline(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baseline.System != "rabbit-tds" || len(baseline.Tests["Lustre"]) != 2 || baseline.Tests["Lustre"][dwsv1alpha7.StateSetup].Duration != 40*time.Second {
+		t.Fatalf("unexpected baseline %+v", baseline)
+	}
+
+	// The baseline of one system is not used for another
+	if err := baseline.CheckSystem("rabbit-tds"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := baseline.CheckSystem("rabbit-dev"); err == nil {
+		t.Errorf("expected an error for another system's baseline")
+	}
+	if err := (&Baseline{}).CheckSystem("rabbit-dev"); err != nil {
+		t.Errorf("unexpected error for a new baseline: %v", err)
+	}
+
+	// Within the threshold
+	if regressions := baseline.Compare(makeResults(48*time.Second, 30*time.Second, true), BaselineStates, 25); len(regressions) != 0 {
+		t.Errorf("unexpected regressions %v", regressions)
+	}
+
+	// Setup drifted from 40s to 3 minutes
+	regressions := baseline.Compare(makeResults(3*time.Minute, 30*time.Second, true), BaselineStates, 25)
+	if len(regressions) != 1 || regressions[0].State != dwsv1alpha7.StateSetup {
+		t.Fatalf("unexpected regressions %v", regressions)
+	}
+	if regressions[0].String() != "Lustre: Setup took 3m0s, baseline 40s (+350%)" {
+		t.Errorf("unexpected regression '%s'", regressions[0])
+	}
+
+	// Failed tests are neither compared nor used to update the baseline
+	if regressions := baseline.Compare(makeResults(3*time.Minute, 30*time.Second, false), BaselineStates, 25); len(regressions) != 0 {
+		t.Errorf("unexpected regressions for a failed test %v", regressions)
+	}
+	baseline.Update(makeResults(3*time.Minute, 30*time.Second, false), BaselineStates)
+	if baseline.Tests["Lustre"][dwsv1alpha7.StateSetup].Duration != 40*time.Second {
+		t.Errorf("baseline updated from a failed test")
+	}
+}
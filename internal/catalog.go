@@ -0,0 +1,555 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+	"github.com/DataWorkflowServices/dws/utils/dwdparse"
+)
+
+const (
+	// DefaultCatalogPath is the shared catalog of test cases that is used when no other
+	// catalog is selected.
+	DefaultCatalogPath = "catalogs"
+
+	// CatalogEnvVar can be set to a comma separated list of catalog files or directories
+	// to use in place of the default catalog.
+	CatalogEnvVar = "NNF_TEST_CATALOG"
+)
+
+// Catalog is the on-disk format of a set of test cases. Catalogs can be written in either
+// YAML or JSON. Each entry is converted to a *T using MakeTest() and the same option methods
+// that are available when writing a test case in Go.
+type Catalog struct {
+	Tests []CatalogTest `json:"tests"`
+}
+
+// CatalogTest describes a single test case in a catalog.
+type CatalogTest struct {
+	Name       string   `json:"name"`
+	Directives []string `json:"directives,omitempty"`
+	Labels     []string `json:"labels,omitempty"`
+
+	// Decorators is a list of ginkgo decorators to apply to the test case. Supported values
+	// are "focused", "pending", and "serial".
+	Decorators []string `json:"decorators,omitempty"`
+
+	// Duplicate the test case this many times. See DuplicateTest().
+	Duplicate int `json:"duplicate,omitempty"`
+
+	// Scenario runs these test cases concurrently, in place of the directives. See MakeScenario().
+	Scenario []CatalogTest `json:"scenario,omitempty"`
+
+	Options CatalogOptions `json:"options,omitempty"`
+}
+
+// CatalogOptions are the catalog equivalents of the TOptions methods on *T.
+type CatalogOptions struct {
+	States               []dwsv1alpha7.WorkflowState `json:"states,omitempty"`
+	SkipStates           []dwsv1alpha7.WorkflowState `json:"skipStates,omitempty"`
+	StopAfter            dwsv1alpha7.WorkflowState   `json:"stopAfter,omitempty"`
+	AbortAfter           dwsv1alpha7.WorkflowState   `json:"abortAfter,omitempty"`
+	DelayInState         []CatalogDelayInState       `json:"delayInState,omitempty"`
+	ExpectError          dwsv1alpha7.WorkflowState   `json:"expectError,omitempty"`
+	ExpectCreateRejected string                      `json:"expectCreateRejected,omitempty"`
+	ErrorMatch           *CatalogErrorMatch          `json:"errorMatch,omitempty"`
+	ExpectTransient      *CatalogExpectTransient     `json:"expectTransient,omitempty"`
+	Stagger              metav1.Duration             `json:"stagger,omitempty"`
+	ExpectWinners        *CatalogExpectWinners       `json:"expectWinners,omitempty"`
+	Soak                 *CatalogSoak                `json:"soak,omitempty"`
+	RejectTransitions    []CatalogTransition         `json:"rejectTransitions,omitempty"`
+	HardwareRequired     bool                        `json:"hardwareRequired,omitempty"`
+	ExternalComputes     bool                        `json:"externalComputes,omitempty"`
+
+	ComputeCount      int      `json:"computeCount,omitempty"`
+	Rabbits           []string `json:"rabbits,omitempty"`
+	ComputesOnRabbits []string `json:"computesOnRabbits,omitempty"`
+
+	// Placement selects the placement policy; see ParsePlacementPolicy()
+	Placement string `json:"placement,omitempty"`
+
+	Timeouts      *CatalogTimeouts                              `json:"timeouts,omitempty"`
+	StateTimeouts map[dwsv1alpha7.WorkflowState]metav1.Duration `json:"stateTimeouts,omitempty"`
+
+	// Permissions sets the workflow's user and group IDs. Any ID that is left unset
+	// defaults to TestUserID or TestGroupID, so `permissions: {}` selects the test user.
+	Permissions *CatalogPermissions `json:"permissions,omitempty"`
+
+	StorageProfile                   *CatalogStorageProfile   `json:"storageProfile,omitempty"`
+	ContainerProfile                 *CatalogContainerProfile `json:"containerProfile,omitempty"`
+	PersistentLustre                 string                   `json:"persistentLustre,omitempty"`
+	GlobalLustre                     *CatalogExternalLustre   `json:"globalLustre,omitempty"`
+	GlobalLustreFromPersistentLustre *CatalogGlobalLustre     `json:"globalLustreFromPersistentLustre,omitempty"`
+	CleanupPersistentInstance        bool                     `json:"cleanupPersistentInstance,omitempty"`
+	MgsPool                          *CatalogMgsPool          `json:"mgsPool,omitempty"`
+}
+
+type CatalogDelayInState struct {
+	State    dwsv1alpha7.WorkflowState `json:"state"`
+	Duration metav1.Duration           `json:"duration"`
+}
+
+// CatalogErrorMatch checks the reason for the error expected by ExpectError. The fields other
+// than Type are regular expressions.
+type CatalogErrorMatch struct {
+	Message       string `json:"message,omitempty"`
+	Driver        string `json:"driver,omitempty"`
+	DriverMessage string `json:"driverMessage,omitempty"`
+	DriverError   string `json:"driverError,omitempty"`
+	Type          string `json:"type,omitempty"`
+}
+
+// CatalogExpectTransient enables ExpectTransient(). The faults scale the named deployments down
+// to zero replicas until the workflow has waited in DriverWait with the controllers stopped.
+type CatalogExpectTransient struct {
+	State            dwsv1alpha7.WorkflowState `json:"state"`
+	Budget           metav1.Duration           `json:"budget"`
+	ScaleDeployments []CatalogDeployment       `json:"scaleDeployments,omitempty"`
+}
+
+// CatalogExpectWinners enables ExpectWinners() for a scenario
+type CatalogExpectWinners struct {
+	State   dwsv1alpha7.WorkflowState `json:"state"`
+	Winners int                       `json:"winners"`
+	Message string                    `json:"message,omitempty"`
+}
+
+// CatalogSoak enables Soak(). The soak stops after the iterations or duration, whichever is first.
+type CatalogSoak struct {
+	Iterations        int             `json:"iterations,omitempty"`
+	Duration          metav1.Duration `json:"duration,omitempty"`
+	ContinueOnFailure bool            `json:"continueOnFailure,omitempty"`
+}
+
+// CatalogTransition is a desired state change expected to be rejected. See
+// ExpectTransitionRejected().
+type CatalogTransition struct {
+	From    dwsv1alpha7.WorkflowState `json:"from"`
+	To      dwsv1alpha7.WorkflowState `json:"to"`
+	Message string                    `json:"message,omitempty"`
+}
+
+type CatalogDeployment struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+type CatalogTimeouts struct {
+	Low    metav1.Duration             `json:"low,omitempty"`
+	High   metav1.Duration             `json:"high,omitempty"`
+	States []dwsv1alpha7.WorkflowState `json:"states,omitempty"`
+}
+
+type CatalogPermissions struct {
+	UserID  *uint32 `json:"userId,omitempty"`
+	GroupID *uint32 `json:"groupId,omitempty"`
+}
+
+// CatalogStorageProfile enables WithStorageProfile(). At most one of the MGS/MGT fields
+// should be set.
+type CatalogStorageProfile struct {
+	ExternalMGS                     string `json:"externalMgs,omitempty"`
+	ExternalMGSFromPersistentLustre bool   `json:"externalMgsFromPersistentLustre,omitempty"`
+	StandaloneMGT                   string `json:"standaloneMgt,omitempty"`
+	LvCreate                        string `json:"lvCreate,omitempty"`
+}
+
+type CatalogContainerProfile struct {
+	Base                    string `json:"base"`
+	ContainerProfileOptions `json:",inline"`
+}
+
+type CatalogGlobalLustre struct {
+	Name       string   `json:"name"`
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// CatalogExternalLustre enables WithGlobalLustre() for an existing lustre file system.
+type CatalogExternalLustre struct {
+	FsName     string   `json:"fsName"`
+	MgsNids    string   `json:"mgsNids"`
+	MountRoot  string   `json:"mountRoot"`
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+type CatalogMgsPool struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// CatalogPaths returns the catalog files or directories to load. The paths are taken from
+// the comma separated value provided, falling back to the NNF_TEST_CATALOG environment
+// variable and finally the default catalog.
+func CatalogPaths(value string) []string {
+	if value == "" {
+		value = os.Getenv(CatalogEnvVar)
+	}
+	if value == "" {
+		value = DefaultCatalogPath
+	}
+
+	paths := make([]string, 0)
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// LoadCatalogs reads each of the provided catalog files and returns the combined list of
+// test cases. A directory loads every .yaml, .yml, and .json file within it in lexical order.
+func LoadCatalogs(paths ...string) ([]*T, error) {
+	files := make([]string, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("catalog '%s': %w", path, err)
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("catalog '%s': %w", path, err)
+		}
+
+		matches := make([]string, 0)
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					matches = append(matches, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	tests := make([]*T, 0)
+	names := make(map[string]string)
+	for _, file := range files {
+		catalog, err := ReadCatalog(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range catalog.Tests {
+			entry := &catalog.Tests[i]
+
+			if other, found := names[entry.Name]; found {
+				return nil, fmt.Errorf("catalog '%s': test '%s' is already defined in '%s'", file, entry.Name, other)
+			}
+			names[entry.Name] = file
+
+			t, err := entry.Build()
+			if err != nil {
+				return nil, fmt.Errorf("catalog '%s': %w", file, err)
+			}
+
+			tests = append(tests, t)
+		}
+	}
+
+	return tests, nil
+}
+
+// ReadCatalog parses a single catalog file. Unknown fields are rejected so that typos in a
+// catalog are reported rather than silently ignored.
+func ReadCatalog(file string) (*Catalog, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("catalog '%s': %w", file, err)
+	}
+
+	catalog := &Catalog{}
+	if err := yaml.UnmarshalStrict(data, catalog); err != nil {
+		return nil, fmt.Errorf("catalog '%s': %w", file, err)
+	}
+
+	return catalog, nil
+}
+
+// Build converts the catalog entry to a test case. The option methods on *T panic when
+// given an invalid configuration; those panics are returned as errors here.
+func (c *CatalogTest) Build() (t *T, err error) {
+	if c.Name == "" {
+		return nil, fmt.Errorf("test name is required")
+	}
+
+	for _, directive := range c.Directives {
+		if _, err := dwdparse.BuildArgsMap(directive); err != nil && c.Options.ExpectCreateRejected == "" {
+			return nil, fmt.Errorf("test '%s': invalid directive '%s': %w", c.Name, directive, err)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t, err = nil, fmt.Errorf("test '%s': %v", c.Name, r)
+		}
+	}()
+
+	if len(c.Scenario) != 0 {
+		if len(c.Directives) != 0 {
+			return nil, fmt.Errorf("test '%s': a scenario cannot have directives", c.Name)
+		}
+
+		tests := make([]*T, len(c.Scenario))
+		for index := range c.Scenario {
+			if tests[index], err = c.Scenario[index].Build(); err != nil {
+				return nil, fmt.Errorf("scenario '%s': %w", c.Name, err)
+			}
+		}
+
+		t = MakeScenario(c.Name, tests...)
+	} else {
+		t = MakeTest(c.Name, c.Directives...)
+	}
+
+	if len(c.Labels) != 0 {
+		t.WithLabels(c.Labels...)
+	}
+
+	if err := c.Options.apply(t); err != nil {
+		return nil, fmt.Errorf("test '%s': %w", c.Name, err)
+	}
+
+	for _, decorator := range c.Decorators {
+		switch strings.ToLower(decorator) {
+		case "focused", "focus":
+			t.Focused()
+		case "pending":
+			t.Pending()
+		case "serial", "serialized":
+			t.Serialized()
+		default:
+			return nil, fmt.Errorf("test '%s': unknown decorator '%s'", c.Name, decorator)
+		}
+	}
+
+	if c.Duplicate > 0 {
+		t = DuplicateTest(t, c.Duplicate)
+	}
+
+	return t, nil
+}
+
+// apply the catalog options to the test. Options are applied in an order that satisfies the
+// dependencies between them (e.g. global lustre requires persistent lustre).
+func (o *CatalogOptions) apply(t *T) error {
+	if o.HardwareRequired {
+		t.HardwareRequired()
+	}
+
+	if len(o.States) != 0 {
+		t.WithStateSequence(o.States...)
+	}
+
+	if len(o.SkipStates) != 0 {
+		t.SkipStates(o.SkipStates...)
+	}
+
+	if o.ExternalComputes {
+		t.WithExternalComputes()
+	}
+
+	if o.ComputeCount != 0 {
+		t.WithComputeCount(o.ComputeCount)
+	}
+
+	if len(o.Rabbits) != 0 {
+		t.WithRabbits(o.Rabbits...)
+	}
+
+	if len(o.ComputesOnRabbits) != 0 {
+		t.WithComputesOnRabbits(o.ComputesOnRabbits...)
+	}
+
+	if o.Placement != "" {
+		policy, err := ParsePlacementPolicy(o.Placement)
+		if err != nil {
+			return err
+		}
+		t.WithPlacementPolicy(policy)
+	}
+
+	if o.Timeouts != nil {
+		t.WithTimeouts(o.Timeouts.Low.Duration, o.Timeouts.High.Duration, o.Timeouts.States...)
+	}
+
+	for state, timeout := range o.StateTimeouts {
+		t.WithStateTimeout(state, timeout.Duration)
+	}
+
+	if o.Permissions != nil {
+		userID, groupID := TestUserID, TestGroupID
+		if o.Permissions.UserID != nil {
+			userID = *o.Permissions.UserID
+		}
+		if o.Permissions.GroupID != nil {
+			groupID = *o.Permissions.GroupID
+		}
+
+		t.WithPermissions(userID, groupID)
+	}
+
+	if o.PersistentLustre != "" {
+		t.WithPersistentLustre(o.PersistentLustre)
+	}
+
+	if o.GlobalLustre != nil && o.GlobalLustreFromPersistentLustre != nil {
+		return fmt.Errorf("globalLustre and globalLustreFromPersistentLustre cannot be combined")
+	}
+
+	if g := o.GlobalLustre; g != nil {
+		t.WithGlobalLustre(g.MountRoot, g.FsName, g.MgsNids, g.Namespaces...)
+	}
+
+	if o.GlobalLustreFromPersistentLustre != nil {
+		t.WithGlobalLustreFromPersistentLustre(o.GlobalLustreFromPersistentLustre.Name, o.GlobalLustreFromPersistentLustre.Namespaces)
+	}
+
+	if p := o.StorageProfile; p != nil {
+		switch {
+		case p.ExternalMGSFromPersistentLustre:
+			t.WithStorageProfileExternalMGSFromPersistentLustre()
+		case p.ExternalMGS != "":
+			t.WithStorageProfileExternalMGS(p.ExternalMGS)
+		case p.StandaloneMGT != "":
+			t.WithStorageProfileStandaloneMGT(p.StandaloneMGT)
+		default:
+			t.WithStorageProfile()
+		}
+
+		// WithStorageProfileLvCreate() would replace the profile configured above, so set the
+		// command directly to allow it to be combined with the MGS/MGT options.
+		if p.LvCreate != "" {
+			t.options.storageProfile.lvCreateCmd = p.LvCreate
+			t.WithLabels("lvCreateCmd")
+		}
+	}
+
+	if p := o.ContainerProfile; p != nil {
+		if p.Base == "" {
+			return fmt.Errorf("container profile requires a base profile")
+		}
+
+		options := p.ContainerProfileOptions
+		t.WithContainerProfile(p.Base, &options)
+	}
+
+	if o.MgsPool != nil {
+		t.WithMgsPool(o.MgsPool.Name, o.MgsPool.Count)
+	}
+
+	if o.CleanupPersistentInstance {
+		t.AndCleanupPersistentInstance()
+	}
+
+	for _, delay := range o.DelayInState {
+		t.DelayInState(delay.State, delay.Duration.Duration)
+	}
+
+	if o.StopAfter != "" {
+		t.StopAfter(o.StopAfter)
+	}
+
+	if o.AbortAfter != "" {
+		t.AbortAfter(o.AbortAfter)
+	}
+
+	if o.ExpectCreateRejected != "" {
+		t.ExpectCreateRejected(o.ExpectCreateRejected)
+	}
+
+	if o.ExpectError != "" {
+		t.ExpectError(o.ExpectError)
+	}
+
+	if e := o.ExpectTransient; e != nil {
+		faults := make([]TransientFault, len(e.ScaleDeployments))
+		for index, deployment := range e.ScaleDeployments {
+			faults[index] = ScaleDeploymentFault(deployment.Namespace, deployment.Name)
+		}
+
+		t.ExpectTransient(e.State, e.Budget.Duration, faults...)
+	}
+
+	if o.Stagger.Duration != 0 {
+		t.Staggered(o.Stagger.Duration)
+	}
+
+	if w := o.ExpectWinners; w != nil {
+		if w.Message != "" {
+			t.ExpectWinners(w.State, w.Winners, w.Message)
+		} else {
+			t.ExpectWinners(w.State, w.Winners)
+		}
+	}
+
+	if soak := o.Soak; soak != nil {
+		t.Soak(SoakPolicy{Iterations: soak.Iterations, Duration: soak.Duration.Duration, ContinueOnFailure: soak.ContinueOnFailure})
+	}
+
+	for _, transition := range o.RejectTransitions {
+		if transition.Message != "" {
+			t.ExpectTransitionRejected(transition.From, transition.To, transition.Message)
+		} else {
+			t.ExpectTransitionRejected(transition.From, transition.To)
+		}
+	}
+
+	if m := o.ErrorMatch; m != nil {
+		if o.ExpectError == "" {
+			return fmt.Errorf("errorMatch requires expectError")
+		}
+		if m.Message != "" {
+			t.WithErrorMessage(m.Message)
+		}
+		if m.Driver != "" {
+			t.WithErrorDriver(m.Driver)
+		}
+		if m.DriverMessage != "" {
+			t.WithErrorDriverMessage(m.DriverMessage)
+		}
+		if m.DriverError != "" {
+			t.WithErrorDriverError(m.DriverError)
+		}
+		if m.Type != "" {
+			t.WithErrorType(m.Type)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,325 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+func TestDefaultCatalog(t *testing.T) {
+	tests, err := LoadCatalogs(filepath.Join("..", DefaultCatalogPath))
+	if err != nil {
+		t.Fatalf("failed to load default catalog: %v", err)
+	}
+
+	if len(tests) == 0 {
+		t.Errorf("default catalog has no tests")
+	}
+}
+
+func TestCatalogOptions(t *testing.T) {
+	catalog := `
+tests:
+  - name: Delay and Stop
+    directives:
+      - "#DW jobdw type=xfs name=delay capacity=50GB profile=my-profile"
+    labels: [simple]
+    decorators: [serial]
+    options:
+      storageProfile: {lvCreate: "--name $LV_NAME $VG_NAME"}
+      permissions: {userId: 1000}
+      delayInState:
+        - {state: DataIn, duration: 15s}
+      stopAfter: PreRun
+`
+
+	file := filepath.Join(t.TempDir(), "catalog.yaml")
+	if err := os.WriteFile(file, []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, err := LoadCatalogs(file)
+	if err != nil {
+		t.Fatalf("failed to load catalog: %v", err)
+	}
+
+	if len(tests) != 1 {
+		t.Fatalf("expected 1 test, found %d", len(tests))
+	}
+
+	test := tests[0]
+	if test.Name() != "Delay and Stop" {
+		t.Errorf("unexpected name '%s'", test.Name())
+	}
+	if test.workflow.Spec.UserID != 1000 || test.workflow.Spec.GroupID != TestGroupID {
+		t.Errorf("unexpected permissions %d:%d", test.workflow.Spec.UserID, test.workflow.Spec.GroupID)
+	}
+	if test.options.storageProfile == nil || test.options.storageProfile.name != "my-profile" || test.options.storageProfile.lvCreateCmd == "" {
+		t.Errorf("storage profile not configured: %+v", test.options.storageProfile)
+	}
+	if len(test.options.delayInState) != 1 || test.options.delayInState[0].duration != 15*time.Second {
+		t.Errorf("delay in state not configured: %+v", test.options.delayInState)
+	}
+	if test.options.stopAfter == nil || test.options.stopAfter.state != dwsv1alpha7.StatePreRun {
+		t.Errorf("stop after not configured: %+v", test.options.stopAfter)
+	}
+	if len(test.decorators) != 1 {
+		t.Errorf("expected 1 decorator, found %d", len(test.decorators))
+	}
+}
+
+func TestCatalogErrors(t *testing.T) {
+	catalogs := map[string]string{
+		"unknown field": `
+tests:
+  - name: Typo
+    directives: ["#DW jobdw type=xfs name=typo capacity=50GB"]
+    option: {stopAfter: PreRun}
+`,
+		"invalid directive": `
+tests:
+  - name: Invalid
+    directives: ["jobdw type=xfs name=invalid capacity=50GB"]
+`,
+		"missing profile": `
+tests:
+  - name: Missing Profile
+    directives: ["#DW jobdw type=xfs name=missing capacity=50GB"]
+    options: {storageProfile: {}}
+`,
+		"error match without expect error": `
+tests:
+  - name: Error Match
+    directives: ["#DW jobdw type=xfs name=match capacity=50GB"]
+    options: {errorMatch: {message: "timeout"}}
+`,
+		"duplicate name": `
+tests:
+  - name: Duplicate
+    directives: ["#DW jobdw type=xfs name=dup capacity=50GB"]
+  - name: Duplicate
+    directives: ["#DW jobdw type=xfs name=dup capacity=50GB"]
+`,
+		"two global lustres": `
+tests:
+  - name: Two Global Lustres
+    directives: ["#DW jobdw type=xfs name=two-global capacity=50GB"]
+    options:
+      persistentLustre: persistent
+      globalLustre: {fsName: lushtx, mgsNids: "10.1.1.113@tcp", mountRoot: /lus/global}
+      globalLustreFromPersistentLustre: {name: persistent-global}
+`,
+		"reject transition to teardown": `
+tests:
+  - name: Reject Teardown
+    directives: ["#DW jobdw type=xfs name=reject-teardown capacity=50GB"]
+    options:
+      rejectTransitions: [{from: PreRun, to: Teardown}]
+`,
+	}
+
+	for name, catalog := range catalogs {
+		file := filepath.Join(t.TempDir(), "catalog.yaml")
+		if err := os.WriteFile(file, []byte(catalog), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := LoadCatalogs(file); err == nil {
+			t.Errorf("%s: expected error loading catalog", name)
+		}
+	}
+}
+
+func TestCatalogExpectTransient(t *testing.T) {
+	catalog := `
+tests:
+  - name: Controller Outage
+    directives: ["#DW jobdw type=xfs name=outage capacity=50GB"]
+    options:
+      expectTransient:
+        state: Setup
+        budget: 5m
+        scaleDeployments: [{namespace: nnf-system, name: nnf-controller-manager}]
+`
+
+	file := filepath.Join(t.TempDir(), "catalog.yaml")
+	if err := os.WriteFile(file, []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, err := LoadCatalogs(file)
+	if err != nil {
+		t.Fatalf("failed to load catalog: %v", err)
+	}
+
+	transient := tests[0].options.expectTransient
+	if transient == nil || transient.state != dwsv1alpha7.StateSetup || transient.budget != 5*time.Minute || len(transient.faults) != 1 {
+		t.Fatalf("expect transient not configured: %+v", transient)
+	}
+	if transient.faults[0].String() != "scale deployment 'nnf-system/nnf-controller-manager' to zero" {
+		t.Errorf("unexpected fault '%s'", transient.faults[0])
+	}
+
+	// A fault affects the whole system, so the test must run serially
+	if len(tests[0].decorators) != 1 {
+		t.Errorf("expected the serial decorator")
+	}
+}
+
+func TestCatalogRejectTransitions(t *testing.T) {
+	catalog := `
+tests:
+  - name: Illegal Transitions
+    directives: ["#DW jobdw type=xfs name=illegal capacity=50GB"]
+    options:
+      rejectTransitions:
+        - {from: PreRun, to: Setup, message: "(?i)state"}
+        - {from: Setup, to: PreRun}
+        - {from: Setup, to: DataIn}
+`
+
+	file := filepath.Join(t.TempDir(), "catalog.yaml")
+	if err := os.WriteFile(file, []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, err := LoadCatalogs(file)
+	if err != nil {
+		t.Fatalf("failed to load catalog: %v", err)
+	}
+
+	transitions := tests[0].options.rejectedTransitions
+	if len(transitions) != 3 {
+		t.Fatalf("expected 3 rejected transitions: %+v", transitions)
+	}
+	if transitions[0].message == nil || transitions[1].message != nil {
+		t.Errorf("unexpected rejection messages: %+v", transitions)
+	}
+
+	// Going backwards and skipping states are checked once Ready, advancing is checked before
+	if transitions[0].beforeReady() || transitions[1].beforeReady() || !transitions[2].beforeReady() {
+		t.Errorf("unexpected before ready transitions: %+v", transitions)
+	}
+}
+
+func TestCatalogExpectCreateRejected(t *testing.T) {
+	catalog := `
+tests:
+  - name: Unparseable Directive
+    directives: ["jobdw type=xfs name=unparseable capacity=50GB"]
+    options: {expectCreateRejected: "(?i)directive"}
+  - name: Unknown Type
+    directives: ["#DW jobdw type=zfs name=unknown-type capacity=50GB"]
+    options: {expectCreateRejected: "(?i)type"}
+`
+
+	file := filepath.Join(t.TempDir(), "catalog.yaml")
+	if err := os.WriteFile(file, []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, err := LoadCatalogs(file)
+	if err != nil {
+		t.Fatalf("failed to load catalog: %v", err)
+	}
+
+	for _, test := range tests {
+		if !test.ExpectsCreateRejected() {
+			t.Errorf("test '%s' does not expect to be rejected", test.Name())
+		}
+
+		// Unparseable directives are permitted when the workflow is expected to be rejected
+		test.Args()
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for an unparseable directive")
+		}
+	}()
+	MakeTest("Unparseable", "jobdw type=xfs name=unparseable capacity=50GB").Args()
+}
+
+func TestCatalogScenario(t *testing.T) {
+	catalog := `
+tests:
+  - name: Capacity Contention
+    decorators: [serial]
+    duplicate: 2
+    scenario:
+      - name: Large 1
+        directives: ["#DW jobdw type=xfs name=large-1 capacity=3TB"]
+        options: {rabbits: [rabbit-node-1]}
+      - name: Large 2
+        directives: ["#DW jobdw type=xfs name=large-2 capacity=3TB"]
+        options: {rabbits: [rabbit-node-1]}
+    options:
+      stagger: 10s
+      expectWinners: {state: Setup, winners: 1, message: "(?i)capacity"}
+`
+
+	file := filepath.Join(t.TempDir(), "catalog.yaml")
+	if err := os.WriteFile(file, []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, err := LoadCatalogs(file)
+	if err != nil {
+		t.Fatalf("failed to load catalog: %v", err)
+	}
+
+	itr := TestIterator(tests)
+	itr.Next()
+	second := itr.Next()
+
+	if !second.IsScenario() {
+		t.Fatalf("test '%s' is not a scenario", second.Name())
+	}
+
+	s := second.options.scenario
+	if s.stagger != 10*time.Second || s.contention == nil || s.contention.state != dwsv1alpha7.StateSetup || s.contention.winners != 1 {
+		t.Errorf("unexpected scenario %+v", s)
+	}
+	if len(s.tests) != 2 || s.tests[1].Name() != "Large 2-1" || s.tests[1].directives[0] != "#DW jobdw type=xfs name=large-2-1 capacity=3TB" {
+		t.Errorf("scenario tests not duplicated: %s %v", s.tests[1].Name(), s.tests[1].directives)
+	}
+	if tests[0].options.scenario.tests[1].Name() != "Large 2" {
+		t.Errorf("original scenario modified")
+	}
+
+	// Scenario options require a scenario
+	if _, err := (&CatalogTest{Name: "Not A Scenario", Directives: []string{"#DW jobdw type=xfs name=x capacity=50GB"},
+		Options: CatalogOptions{ExpectWinners: &CatalogExpectWinners{State: dwsv1alpha7.StateSetup, Winners: 1}}}).Build(); err == nil {
+		t.Errorf("expected an error for expectWinners without a scenario")
+	}
+
+	// Scenarios execute the default states, so options that change them are rejected
+	if _, err := (&CatalogTest{Name: "Stop Scenario", Scenario: []CatalogTest{
+		{Name: "Stop 1", Directives: []string{"#DW jobdw type=xfs name=stop-1 capacity=50GB"}, Options: CatalogOptions{StopAfter: dwsv1alpha7.StateSetup}},
+		{Name: "Stop 2", Directives: []string{"#DW jobdw type=xfs name=stop-2 capacity=50GB"}},
+	}}).Build(); err == nil {
+		t.Errorf("expected an error for stopAfter in a scenario test")
+	}
+}
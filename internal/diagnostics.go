@@ -0,0 +1,284 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	. "github.com/onsi/ginkgo/v2"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+	"github.com/DataWorkflowServices/dws/utils/dwdparse"
+	nnfv1alpha11 "github.com/NearNodeFlash/nnf-sos/api/v1alpha11"
+)
+
+// ArtifactsEnvVar selects the artifacts directory when the `-artifacts` flag is not provided
+const ArtifactsEnvVar = "NNF_TEST_ARTIFACTS"
+
+// DefaultArtifactsPath is the artifacts directory used when none is configured
+const DefaultArtifactsPath = "artifacts"
+
+// ArtifactsContextKey is the context key for the suite's artifacts directory
+const ArtifactsContextKey = "artifacts"
+
+// ArtifactDir returns the directory holding the artifacts collected for the workflow
+func ArtifactDir(ctx context.Context, workflow *dwsv1alpha7.Workflow) string {
+	root, ok := ctx.Value(ArtifactsContextKey).(string)
+	if !ok || root == "" {
+		root = DefaultArtifactsPath
+	}
+
+	return filepath.Join(root, workflow.Name)
+}
+
+// diagnosticKind is a kind of object that may be related to a workflow
+type diagnosticKind struct {
+	kind string
+	list func() client.ObjectList
+	opts []client.ListOption
+}
+
+// diagnosticKinds are the kinds of object collected for a workflow, roughly in the order they are
+// created. Pods are limited to those carrying the DWS owner labels.
+var diagnosticKinds = []diagnosticKind{
+	{kind: "DirectiveBreakdown", list: func() client.ObjectList { return &dwsv1alpha7.DirectiveBreakdownList{} }},
+	{kind: "Servers", list: func() client.ObjectList { return &dwsv1alpha7.ServersList{} }},
+	{kind: "Computes", list: func() client.ObjectList { return &dwsv1alpha7.ComputesList{} }},
+	{kind: "PersistentStorageInstance", list: func() client.ObjectList { return &dwsv1alpha7.PersistentStorageInstanceList{} }},
+	{kind: "NnfStorage", list: func() client.ObjectList { return &nnfv1alpha11.NnfStorageList{} }},
+	{kind: "NnfNodeStorage", list: func() client.ObjectList { return &nnfv1alpha11.NnfNodeStorageList{} }},
+	{kind: "NnfNodeBlockStorage", list: func() client.ObjectList { return &nnfv1alpha11.NnfNodeBlockStorageList{} }},
+	{kind: "NnfAccess", list: func() client.ObjectList { return &nnfv1alpha11.NnfAccessList{} }},
+	{kind: "ClientMount", list: func() client.ObjectList { return &dwsv1alpha7.ClientMountList{} }},
+	{kind: "NnfDataMovement", list: func() client.ObjectList { return &nnfv1alpha11.NnfDataMovementList{} }},
+	{kind: "Pod", list: func() client.ObjectList { return &corev1.PodList{} }, opts: []client.ListOption{client.HasLabels{dwsv1alpha7.OwnerKindLabel}}},
+}
+
+// diagnosticObject is an object in the workflow's object graph
+type diagnosticObject struct {
+	kind   string
+	object client.Object
+}
+
+func diagnosticKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// diagnosticGraph is the set of objects related to a workflow
+type diagnosticGraph struct {
+	workflow *dwsv1alpha7.Workflow
+	objects  map[string]diagnosticObject
+
+	// Names of the persistent storage instances used by the workflow's directives
+	persistent []string
+}
+
+// related returns true if the object belongs to the workflow; i.e. it carries the workflow's
+// labels, it is owned by an object already in the graph, or it is a persistent storage instance
+// used by the workflow.
+func (g *diagnosticGraph) related(kind string, object client.Object) bool {
+	labels := object.GetLabels()
+	if labels[dwsv1alpha7.WorkflowNameLabel] == g.workflow.Name && labels[dwsv1alpha7.WorkflowNamespaceLabel] == g.workflow.Namespace {
+		return true
+	}
+
+	if ownerKind, found := labels[dwsv1alpha7.OwnerKindLabel]; found {
+		if _, found := g.objects[diagnosticKey(ownerKind, labels[dwsv1alpha7.OwnerNamespaceLabel], labels[dwsv1alpha7.OwnerNameLabel])]; found {
+			return true
+		}
+	}
+
+	if kind == "PersistentStorageInstance" && object.GetNamespace() == g.workflow.Namespace {
+		for _, name := range g.persistent {
+			if object.GetName() == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// persistentNames returns the names of the persistent storage instances created, used or
+// destroyed by the directives
+func persistentNames(directives []string) []string {
+	names := make([]string, 0)
+	for _, directive := range directives {
+		args, err := dwdparse.BuildArgsMap(directive)
+		if err != nil {
+			continue
+		}
+
+		switch args["command"] {
+		case "create_persistent", "persistentdw", "destroy_persistent":
+			names = append(names, args["name"])
+		}
+	}
+
+	return names
+}
+
+// buildDiagnosticGraph walks the workflow's object graph. Objects are found by their workflow or
+// owner labels, so children of children (e.g. the NnfNodeStorages of an NnfStorage) are found
+// too. Kinds that could not be listed are returned as errors alongside the partial graph.
+func buildDiagnosticGraph(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow) (*diagnosticGraph, []string) {
+	graph := &diagnosticGraph{
+		workflow:   workflow,
+		objects:    make(map[string]diagnosticObject),
+		persistent: persistentNames(workflow.Spec.DWDirectives),
+	}
+
+	current := &dwsv1alpha7.Workflow{}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), current); err == nil {
+		graph.objects[diagnosticKey("Workflow", workflow.Namespace, workflow.Name)] = diagnosticObject{kind: "Workflow", object: current}
+	} else {
+		// The workflow may be gone but its children may remain
+		graph.objects[diagnosticKey("Workflow", workflow.Namespace, workflow.Name)] = diagnosticObject{kind: "Workflow", object: workflow}
+	}
+
+	candidates := make([]diagnosticObject, 0)
+	errs := make([]string, 0)
+	for _, kind := range diagnosticKinds {
+		list := kind.list()
+		if err := k8sClient.List(ctx, list, kind.opts...); err != nil {
+			errs = append(errs, fmt.Sprintf("list %s: %v", kind.kind, err))
+			continue
+		}
+
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("extract %s: %v", kind.kind, err))
+			continue
+		}
+
+		for _, item := range items {
+			if object, ok := item.(client.Object); ok {
+				candidates = append(candidates, diagnosticObject{kind: kind.kind, object: object})
+			}
+		}
+	}
+
+	// Walk the graph until no more objects are added, as an object may be listed before its owner
+	for added := true; added; {
+		added = false
+		for _, candidate := range candidates {
+			key := diagnosticKey(candidate.kind, candidate.object.GetNamespace(), candidate.object.GetName())
+			if _, found := graph.objects[key]; found || !graph.related(candidate.kind, candidate.object) {
+				continue
+			}
+
+			graph.objects[key] = candidate
+			added = true
+		}
+	}
+
+	return graph, errs
+}
+
+// CollectDiagnostics walks the workflow's object graph and writes the workflow and each related
+// object as YAML to the directory, one file per object. Returns the number of objects written.
+func CollectDiagnostics(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, dir string) (int, error) {
+	graph, errs := buildDiagnosticGraph(ctx, k8sClient, workflow)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	keys := make([]string, 0, len(graph.objects))
+	for key := range graph.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := writeDiagnosticObject(dir, graph.objects[key]); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) != 0 {
+		return len(keys), fmt.Errorf("collecting diagnostics for workflow '%s': %s", workflow.Name, strings.Join(errs, "; "))
+	}
+
+	return len(keys), nil
+}
+
+// writeDiagnosticObject writes the object as YAML to a file named for its kind, namespace and name
+func writeDiagnosticObject(dir string, d diagnosticObject) error {
+	object := d.object.DeepCopyObject().(client.Object)
+	object.SetManagedFields(nil)
+
+	data, err := yaml.Marshal(object)
+	if err != nil {
+		return fmt.Errorf("marshal %s '%s': %w", d.kind, client.ObjectKeyFromObject(object), err)
+	}
+
+	data = append([]byte(fmt.Sprintf("# %s\n", d.kind)), data...)
+
+	name := fmt.Sprintf("%s-%s-%s.yaml", strings.ToLower(d.kind), object.GetNamespace(), object.GetName())
+	return os.WriteFile(filepath.Join(dir, name), data, 0644)
+}
+
+// ReportDiagnostics collects the diagnostics of the workflow into its artifact directory and
+// returns a summary, including the directory's location, for inclusion in test failure reports
+func ReportDiagnostics(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow) string {
+	dir := filepath.Join(ArtifactDir(ctx, workflow), "objects")
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	count, err := CollectDiagnostics(ctx, k8sClient, workflow, dir)
+	if err != nil {
+		return fmt.Sprintf("Wrote %d object(s) to file://%s\nErrors: %v", count, dir, err)
+	}
+
+	return fmt.Sprintf("Wrote %d object(s) to file://%s", count, dir)
+}
+
+// ReportDiagnostics adds the diagnostics of the test's workflow, or of each workflow in a
+// scenario, to the spec report
+func (t *T) ReportDiagnostics(ctx context.Context, k8sClient client.Client) {
+	for _, workflow := range t.workflows() {
+		AddReportEntry(fmt.Sprintf("Diagnostics for '%s'", workflow.Name), ReportDiagnostics(ctx, k8sClient, workflow))
+	}
+}
+
+// ReportWorkflowStatus adds the status of the test's failed workflow, or of each workflow in a
+// scenario, to the spec report. The status is refreshed if the workflow still exists.
+func (t *T) ReportWorkflowStatus(ctx context.Context, k8sClient client.Client) {
+	for _, workflow := range t.workflows() {
+		current := workflow.DeepCopy()
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), current); err != nil {
+			current = workflow
+		}
+
+		AddReportEntry(fmt.Sprintf("Workflow '%s' Failed", workflow.Name), current.Status)
+	}
+}
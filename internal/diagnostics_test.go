@@ -0,0 +1,81 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+	nnfv1alpha11 "github.com/NearNodeFlash/nnf-sos/api/v1alpha11"
+)
+
+func TestDiagnosticGraphRelated(t *testing.T) {
+	workflow := MakeTest("Graph", "#DW jobdw type=xfs name=graph capacity=50GB", "#DW persistentdw name=shared").Workflow()
+
+	graph := &diagnosticGraph{
+		workflow:   workflow,
+		objects:    make(map[string]diagnosticObject),
+		persistent: persistentNames(workflow.Spec.DWDirectives),
+	}
+	graph.objects[diagnosticKey("Workflow", workflow.Namespace, workflow.Name)] = diagnosticObject{kind: "Workflow", object: workflow}
+
+	if !slices.Equal(graph.persistent, []string{"shared"}) {
+		t.Errorf("expected persistent names [shared], got %v", graph.persistent)
+	}
+
+	storage := &nnfv1alpha11.NnfStorage{}
+	storage.ObjectMeta = metav1.ObjectMeta{Name: "graph-0", Namespace: workflow.Namespace, Labels: map[string]string{
+		dwsv1alpha7.WorkflowNameLabel:      workflow.Name,
+		dwsv1alpha7.WorkflowNamespaceLabel: workflow.Namespace,
+	}}
+	if !graph.related("NnfStorage", storage) {
+		t.Errorf("expected storage with the workflow labels to be related")
+	}
+
+	nodeStorage := &nnfv1alpha11.NnfNodeStorage{}
+	nodeStorage.ObjectMeta = metav1.ObjectMeta{Name: "graph-0", Namespace: "rabbit-node-1", Labels: map[string]string{
+		dwsv1alpha7.OwnerKindLabel:      "NnfStorage",
+		dwsv1alpha7.OwnerNameLabel:      storage.Name,
+		dwsv1alpha7.OwnerNamespaceLabel: storage.Namespace,
+	}}
+	if graph.related("NnfNodeStorage", nodeStorage) {
+		t.Errorf("expected node storage to be unrelated until its owner is in the graph")
+	}
+
+	graph.objects[diagnosticKey("NnfStorage", storage.Namespace, storage.Name)] = diagnosticObject{kind: "NnfStorage", object: storage}
+	if !graph.related("NnfNodeStorage", nodeStorage) {
+		t.Errorf("expected node storage owned by the storage to be related")
+	}
+
+	psi := &dwsv1alpha7.PersistentStorageInstance{}
+	psi.ObjectMeta = metav1.ObjectMeta{Name: "shared", Namespace: workflow.Namespace}
+	if !graph.related("PersistentStorageInstance", psi) {
+		t.Errorf("expected the persistent storage instance used by the workflow to be related")
+	}
+
+	other := &dwsv1alpha7.PersistentStorageInstance{}
+	other.ObjectMeta = metav1.ObjectMeta{Name: "other", Namespace: workflow.Namespace}
+	if graph.related("PersistentStorageInstance", other) {
+		t.Errorf("expected an unused persistent storage instance to be unrelated")
+	}
+}
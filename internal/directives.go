@@ -0,0 +1,365 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Directive is a typed #DW directive builder. Build() validates the directive's arguments and
+// returns the directive string used in the workflow. For example, the following builds the
+// directives for a data movement test where the copy_in/copy_out references to the job storage
+// are generated from the jobdw directive.
+//
+//	jobdw := JobDW("xfs", "xfs-data-movement", "50GB")
+//	MakeTestFromDirectives("XFS with Data Movement",
+//		jobdw,
+//		CopyIn("/lus/zenith/testuser/test.in", jobdw.Path()),
+//		CopyOut(jobdw.Path("test.in"), "/lus/zenith/testuser/test.out"),
+//	)
+type Directive interface {
+	Build() (string, error)
+}
+
+var (
+	directiveNameRegex     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	directiveCapacityRegex = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?([KMGTP]i?B)?$`)
+	containerStorageRegex  = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+	directiveFileSystemTypes = []string{"xfs", "gfs2", "lustre", "raw"}
+)
+
+// MakeTestFromDirectives makes a test from typed directive builders. Like MakeTest(), it panics
+// if any of the directives are invalid.
+func MakeTestFromDirectives(name string, directives ...Directive) *T {
+	dws := make([]string, len(directives))
+	for index, directive := range directives {
+		dw, err := directive.Build()
+		if err != nil {
+			panic(fmt.Sprintf("Test '%s' has an invalid directive: %v", name, err))
+		}
+
+		dws[index] = dw
+	}
+
+	return MakeTest(name, dws...)
+}
+
+// directiveArgs formats the directive from its command and key=value arguments. Arguments with
+// an empty value are omitted.
+func directiveArgs(command string, args ...string) string {
+	fields := []string{"#DW", command}
+	for index := 0; index+1 < len(args); index += 2 {
+		if args[index+1] != "" {
+			fields = append(fields, args[index]+"="+args[index+1])
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
+
+func validateName(command, name string) error {
+	if !directiveNameRegex.MatchString(name) {
+		return fmt.Errorf("%s: invalid name '%s'", command, name)
+	}
+
+	return nil
+}
+
+func validateFileSystem(command, fsType, capacity string) error {
+	valid := false
+	for _, t := range directiveFileSystemTypes {
+		valid = valid || t == fsType
+	}
+	if !valid {
+		return fmt.Errorf("%s: invalid type '%s', expected one of %v", command, fsType, directiveFileSystemTypes)
+	}
+
+	if !directiveCapacityRegex.MatchString(capacity) {
+		return fmt.Errorf("%s: invalid capacity '%s'", command, capacity)
+	}
+
+	return nil
+}
+
+func validatePath(command, key, path string) error {
+	if path == "" || strings.ContainsAny(path, " \t\n") {
+		return fmt.Errorf("%s: invalid %s '%s'", command, key, path)
+	}
+
+	return nil
+}
+
+func mustBuild(directive Directive) string {
+	dw, err := directive.Build()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return dw
+}
+
+// JobDWDirective builds a `#DW jobdw` directive
+type JobDWDirective struct {
+	fsType   string
+	name     string
+	capacity string
+	profile  string
+	requires []string
+}
+
+func JobDW(fsType, name, capacity string) *JobDWDirective {
+	return &JobDWDirective{fsType: fsType, name: name, capacity: capacity}
+}
+
+func (d *JobDWDirective) WithProfile(profile string) *JobDWDirective {
+	d.profile = profile
+	return d
+}
+
+func (d *JobDWDirective) Requires(requires ...string) *JobDWDirective {
+	d.requires = append(d.requires, requires...)
+	return d
+}
+
+func (d *JobDWDirective) Name() string { return d.name }
+
+// Path returns a path within the job storage (i.e. $DW_JOB_<name>/path)
+func (d *JobDWDirective) Path(elem ...string) string {
+	return "$DW_JOB_" + d.name + "/" + strings.Join(elem, "/")
+}
+
+func (d *JobDWDirective) Build() (string, error) {
+	if err := validateName("jobdw", d.name); err != nil {
+		return "", err
+	}
+	if err := validateFileSystem("jobdw", d.fsType, d.capacity); err != nil {
+		return "", err
+	}
+	if d.profile != "" {
+		if err := validateName("jobdw", d.profile); err != nil {
+			return "", err
+		}
+	}
+
+	return directiveArgs("jobdw", "type", d.fsType, "name", d.name, "capacity", d.capacity, "profile", d.profile, "requires", strings.Join(d.requires, ",")), nil
+}
+
+func (d *JobDWDirective) String() string { return mustBuild(d) }
+
+// CreatePersistentDirective builds a `#DW create_persistent` directive
+type CreatePersistentDirective struct {
+	fsType   string
+	name     string
+	capacity string
+	profile  string
+}
+
+func CreatePersistent(fsType, name, capacity string) *CreatePersistentDirective {
+	return &CreatePersistentDirective{fsType: fsType, name: name, capacity: capacity}
+}
+
+func (d *CreatePersistentDirective) WithProfile(profile string) *CreatePersistentDirective {
+	d.profile = profile
+	return d
+}
+
+func (d *CreatePersistentDirective) Name() string { return d.name }
+
+// PersistentDW returns a persistentdw directive that uses this persistent storage
+func (d *CreatePersistentDirective) PersistentDW() *PersistentDWDirective {
+	return PersistentDW(d.name)
+}
+
+// Destroy returns a destroy_persistent directive for this persistent storage
+func (d *CreatePersistentDirective) Destroy() *DestroyPersistentDirective {
+	return DestroyPersistent(d.name)
+}
+
+func (d *CreatePersistentDirective) Build() (string, error) {
+	if err := validateName("create_persistent", d.name); err != nil {
+		return "", err
+	}
+
+	// Capacity is optional for persistent storage that does not allocate any (e.g. a
+	// standalone MGT) and is only validated when provided.
+	capacity := d.capacity
+	if capacity == "" {
+		capacity = "0"
+	}
+	if err := validateFileSystem("create_persistent", d.fsType, capacity); err != nil {
+		return "", err
+	}
+	if d.profile != "" {
+		if err := validateName("create_persistent", d.profile); err != nil {
+			return "", err
+		}
+	}
+
+	return directiveArgs("create_persistent", "type", d.fsType, "name", d.name, "capacity", d.capacity, "profile", d.profile), nil
+}
+
+func (d *CreatePersistentDirective) String() string { return mustBuild(d) }
+
+// DestroyPersistentDirective builds a `#DW destroy_persistent` directive
+type DestroyPersistentDirective struct {
+	name string
+}
+
+func DestroyPersistent(name string) *DestroyPersistentDirective {
+	return &DestroyPersistentDirective{name: name}
+}
+
+func (d *DestroyPersistentDirective) Name() string { return d.name }
+
+func (d *DestroyPersistentDirective) Build() (string, error) {
+	if err := validateName("destroy_persistent", d.name); err != nil {
+		return "", err
+	}
+
+	return directiveArgs("destroy_persistent", "name", d.name), nil
+}
+
+func (d *DestroyPersistentDirective) String() string { return mustBuild(d) }
+
+// PersistentDWDirective builds a `#DW persistentdw` directive
+type PersistentDWDirective struct {
+	name string
+}
+
+func PersistentDW(name string) *PersistentDWDirective {
+	return &PersistentDWDirective{name: name}
+}
+
+func (d *PersistentDWDirective) Name() string { return d.name }
+
+// Path returns a path within the persistent storage (i.e. $DW_PERSISTENT_<name>/path)
+func (d *PersistentDWDirective) Path(elem ...string) string {
+	return "$DW_PERSISTENT_" + d.name + "/" + strings.Join(elem, "/")
+}
+
+func (d *PersistentDWDirective) Build() (string, error) {
+	if err := validateName("persistentdw", d.name); err != nil {
+		return "", err
+	}
+
+	return directiveArgs("persistentdw", "name", d.name), nil
+}
+
+func (d *PersistentDWDirective) String() string { return mustBuild(d) }
+
+// CopyDirective builds a `#DW copy_in` or `#DW copy_out` directive
+type CopyDirective struct {
+	command     string
+	source      string
+	destination string
+	profile     string
+}
+
+func CopyIn(source, destination string) *CopyDirective {
+	return &CopyDirective{command: "copy_in", source: source, destination: destination}
+}
+
+func CopyOut(source, destination string) *CopyDirective {
+	return &CopyDirective{command: "copy_out", source: source, destination: destination}
+}
+
+func (d *CopyDirective) WithProfile(profile string) *CopyDirective {
+	d.profile = profile
+	return d
+}
+
+func (d *CopyDirective) Build() (string, error) {
+	if err := validatePath(d.command, "source", d.source); err != nil {
+		return "", err
+	}
+	if err := validatePath(d.command, "destination", d.destination); err != nil {
+		return "", err
+	}
+	if d.profile != "" {
+		if err := validateName(d.command, d.profile); err != nil {
+			return "", err
+		}
+	}
+
+	return directiveArgs(d.command, "profile", d.profile, "source", d.source, "destination", d.destination), nil
+}
+
+func (d *CopyDirective) String() string { return mustBuild(d) }
+
+// ContainerDirective builds a `#DW container` directive
+type ContainerDirective struct {
+	name     string
+	profile  string
+	storages []string // alternating key, value pairs
+}
+
+func Container(name, profile string) *ContainerDirective {
+	return &ContainerDirective{name: name, profile: profile}
+}
+
+// WithJobStorage adds the jobdw storage to the container as DW_JOB_<key>
+func (d *ContainerDirective) WithJobStorage(key string, storage *JobDWDirective) *ContainerDirective {
+	d.storages = append(d.storages, "DW_JOB_"+key, storage.Name())
+	return d
+}
+
+// WithPersistentStorage adds the persistentdw storage to the container as DW_PERSISTENT_<key>
+func (d *ContainerDirective) WithPersistentStorage(key string, storage *PersistentDWDirective) *ContainerDirective {
+	d.storages = append(d.storages, "DW_PERSISTENT_"+key, storage.Name())
+	return d
+}
+
+// WithGlobalStorage adds the global lustre file system mounted at mountRoot to the container
+// as DW_GLOBAL_<key>
+func (d *ContainerDirective) WithGlobalStorage(key string, mountRoot string) *ContainerDirective {
+	d.storages = append(d.storages, "DW_GLOBAL_"+key, mountRoot)
+	return d
+}
+
+func (d *ContainerDirective) Build() (string, error) {
+	if err := validateName("container", d.name); err != nil {
+		return "", err
+	}
+	if err := validateName("container", d.profile); err != nil {
+		return "", err
+	}
+
+	for index := 0; index < len(d.storages); index += 2 {
+		key, value := d.storages[index], d.storages[index+1]
+		if !containerStorageRegex.MatchString(key) {
+			return "", fmt.Errorf("container: invalid storage '%s'", key)
+		}
+
+		if strings.HasPrefix(key, "DW_GLOBAL_") {
+			if err := validatePath("container", key, value); err != nil {
+				return "", err
+			}
+		} else if err := validateName("container", value); err != nil {
+			return "", err
+		}
+	}
+
+	return directiveArgs("container", append([]string{"name", d.name, "profile", d.profile}, d.storages...)...), nil
+}
+
+func (d *ContainerDirective) String() string { return mustBuild(d) }
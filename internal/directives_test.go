@@ -0,0 +1,79 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDirectiveBuilders(t *testing.T) {
+	jobdw := JobDW("gfs2", "local-storage", "100GB").Requires("copy-offload")
+	create := CreatePersistent("lustre", "persistent-storage", "50GB").WithProfile("my-profile")
+	persistentdw := create.PersistentDW()
+
+	test := MakeTestFromDirectives("Builders",
+		jobdw,
+		persistentdw,
+		CopyIn("/lus/zenith/testuser/test.in", jobdw.Path()),
+		CopyOut(jobdw.Path("test.in"), "/lus/zenith/testuser/test.out").WithProfile("no-xattr"),
+		Container("containers", "example-success").
+			WithJobStorage("foo_local_storage", jobdw).
+			WithPersistentStorage("foo_persistent_storage", persistentdw).
+			WithGlobalStorage("foo_global_lustre", "/lus/zenith"),
+	)
+
+	expected := []string{
+		"#DW jobdw type=gfs2 name=local-storage capacity=100GB requires=copy-offload",
+		"#DW persistentdw name=persistent-storage",
+		"#DW copy_in source=/lus/zenith/testuser/test.in destination=$DW_JOB_local-storage/",
+		"#DW copy_out profile=no-xattr source=$DW_JOB_local-storage/test.in destination=/lus/zenith/testuser/test.out",
+		"#DW container name=containers profile=example-success DW_JOB_foo_local_storage=local-storage DW_PERSISTENT_foo_persistent_storage=persistent-storage DW_GLOBAL_foo_global_lustre=/lus/zenith",
+	}
+	if !reflect.DeepEqual(test.directives, expected) {
+		t.Errorf("unexpected directives:\n%v\nexpected:\n%v", test.directives, expected)
+	}
+
+	if create.String() != "#DW create_persistent type=lustre name=persistent-storage capacity=50GB profile=my-profile" {
+		t.Errorf("unexpected create_persistent '%s'", create)
+	}
+	if create.Destroy().String() != "#DW destroy_persistent name=persistent-storage" {
+		t.Errorf("unexpected destroy_persistent '%s'", create.Destroy())
+	}
+}
+
+func TestDirectiveValidation(t *testing.T) {
+	directives := map[string]Directive{
+		"type":             JobDW("zfs", "invalid-type", "50GB"),
+		"capacity":         JobDW("xfs", "invalid-capacity", "50 GB"),
+		"capacity units":   JobDW("xfs", "invalid-capacity", "50GiGa"),
+		"name":             JobDW("xfs", "Invalid_Name", "50GB"),
+		"persistent":       CreatePersistent("lustre", "", "50GB"),
+		"copy source":      CopyIn("", "$DW_JOB_foo/"),
+		"container key":    Container("containers", "example-success").WithGlobalStorage("foo-bar", "/lus/zenith"),
+		"container global": Container("containers", "example-success").WithGlobalStorage("foo", ""),
+	}
+
+	for name, directive := range directives {
+		if dw, err := directive.Build(); err == nil {
+			t.Errorf("%s: expected validation error for '%s'", name, dw)
+		}
+	}
+}
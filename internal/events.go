@@ -0,0 +1,360 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/ginkgo/v2"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+// EventsFile is the name of the file in the test's artifact directory holding its event timeline
+const EventsFile = "events.txt"
+
+// eventRefreshInterval is how often the recorder looks for new children of the workflows when
+// events arrive for objects it does not know
+const eventRefreshInterval = 5 * time.Second
+
+// TimelineEvent is a Kubernetes event for the workflow or one of its children
+type TimelineEvent struct {
+	Time      time.Time
+	Type      string
+	Kind      string
+	Namespace string
+	Name      string
+	Reason    string
+	Message   string
+	Count     int32
+}
+
+// EventTimeline are the events of a test in time order
+type EventTimeline struct {
+	Start  time.Time
+	Events []TimelineEvent
+}
+
+func (timeline EventTimeline) String() string {
+	if len(timeline.Events) == 0 {
+		return "No events recorded"
+	}
+
+	b := &strings.Builder{}
+	for _, event := range timeline.Events {
+		count := ""
+		if event.Count > 1 {
+			count = fmt.Sprintf(" (x%d)", event.Count)
+		}
+
+		fmt.Fprintf(b, "%s %8s %-7s %s %s/%s %s: %s%s\n", event.Time.Format("15:04:05"),
+			"+"+event.Time.Sub(timeline.Start).Round(time.Second).String(), event.Type,
+			event.Kind, event.Namespace, event.Name, event.Reason, event.Message, count)
+	}
+
+	return b.String()
+}
+
+// EventRecorder records the Kubernetes events for one or more workflows and their children from
+// the time it is started until it is stopped. Events are watched when the client supports it and
+// listed when the recorder is stopped, so events that arrive while the watch is re-established are
+// not lost.
+//
+// Only the events of workflows and the kinds of object collected for the diagnostics are kept.
+// Children of the workflows are found as for the diagnostics (see CollectDiagnostics()) whenever an
+// event arrives for an object not yet known whose name starts with a workflow's name, and are
+// remembered after they are deleted. A child created and deleted between two refreshes may be
+// missed.
+type EventRecorder struct {
+	k8sClient client.Client
+	workflows []*dwsv1alpha7.Workflow
+	start     time.Time
+
+	lock    sync.Mutex
+	events  map[types.UID]corev1.Event
+	related map[string]bool
+	unknown bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// StartEventRecorder starts recording the events of the workflows and their children
+func StartEventRecorder(ctx context.Context, k8sClient client.Client, workflows ...*dwsv1alpha7.Workflow) *EventRecorder {
+	r := &EventRecorder{
+		k8sClient: k8sClient,
+		workflows: workflows,
+		start:     time.Now(),
+		events:    make(map[types.UID]corev1.Event),
+		related:   make(map[string]bool),
+	}
+
+	for _, workflow := range workflows {
+		r.related[diagnosticKey("Workflow", workflow.Namespace, workflow.Name)] = true
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+
+	if watchClient, ok := k8sClient.(client.WithWatch); ok {
+		r.wg.Add(2)
+		go r.watch(watchCtx, watchClient)
+		go r.refreshPeriodically(watchCtx)
+	}
+
+	return r
+}
+
+// RecordEvents starts recording the events of the test's workflow, or of each workflow in a
+// scenario
+func (t *T) RecordEvents(ctx context.Context, k8sClient client.Client) *EventRecorder {
+	return StartEventRecorder(ctx, k8sClient, t.workflows()...)
+}
+
+// watch records the events until the context is done, re-establishing the watch as necessary
+func (r *EventRecorder) watch(ctx context.Context, watchClient client.WithWatch) {
+	defer r.wg.Done()
+
+	for ctx.Err() == nil {
+		watcher, err := watchClient.Watch(ctx, &corev1.EventList{})
+		if err != nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		func() {
+			defer watcher.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case result, ok := <-watcher.ResultChan():
+					if !ok {
+						return
+					}
+					if event, ok := result.Object.(*corev1.Event); ok {
+						r.record(event)
+					}
+				}
+			}
+		}()
+	}
+}
+
+// refreshPeriodically looks for new children of the workflows when events have arrived for
+// objects the recorder does not know
+func (r *EventRecorder) refreshPeriodically(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(eventRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.lock.Lock()
+			unknown := r.unknown
+			r.unknown = false
+			r.lock.Unlock()
+
+			if unknown {
+				r.refresh(ctx)
+			}
+		}
+	}
+}
+
+// refresh adds the current children of the workflows to the related objects
+func (r *EventRecorder) refresh(ctx context.Context) {
+	for _, workflow := range r.workflows {
+		graph, _ := buildDiagnosticGraph(ctx, r.k8sClient, workflow)
+
+		r.lock.Lock()
+		for key := range graph.objects {
+			r.related[key] = true
+		}
+		r.lock.Unlock()
+	}
+}
+
+// eventTime returns the most recent time of the event
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+
+	return event.CreationTimestamp.Time
+}
+
+func involvedKey(event *corev1.Event) string {
+	return diagnosticKey(event.InvolvedObject.Kind, event.InvolvedObject.Namespace, event.InvolvedObject.Name)
+}
+
+// isEventKind returns true if the kind is that of a workflow or one of its children
+func isEventKind(kind string) bool {
+	if kind == "Workflow" {
+		return true
+	}
+
+	for _, diagnosticKind := range diagnosticKinds {
+		if diagnosticKind.kind == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mayBeChild returns true if the event is for an object that may be a child of the workflows not
+// yet found by a refresh. Children are named after their workflow.
+func (r *EventRecorder) mayBeChild(event *corev1.Event) bool {
+	if event.InvolvedObject.Kind == "Workflow" || r.related[involvedKey(event)] {
+		return false
+	}
+
+	for _, workflow := range r.workflows {
+		if strings.HasPrefix(event.InvolvedObject.Name, workflow.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// record keeps the latest version of the event if it occurred after the recorder started and is
+// for a workflow or a kind of object that may be one of its children. Event times may only have a
+// resolution of a second.
+func (r *EventRecorder) record(event *corev1.Event) {
+	if eventTime(event).Before(r.start.Truncate(time.Second)) || !isEventKind(event.InvolvedObject.Kind) {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.events[event.UID] = *event
+	if r.mayBeChild(event) {
+		r.unknown = true
+	}
+}
+
+// prune removes the events of unrelated objects
+func (r *EventRecorder) prune() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for uid, event := range r.events {
+		if !r.related[involvedKey(&event)] {
+			delete(r.events, uid)
+		}
+	}
+}
+
+// Stop stops recording and returns the timeline of the events of the workflows and their children
+func (r *EventRecorder) Stop(ctx context.Context) EventTimeline {
+	r.cancel()
+	r.wg.Wait()
+
+	events := &corev1.EventList{}
+	if err := r.k8sClient.List(ctx, events); err == nil {
+		for index := range events.Items {
+			r.record(&events.Items[index])
+		}
+	}
+
+	r.refresh(ctx)
+	r.prune()
+
+	return r.timeline()
+}
+
+// timeline returns the recorded events of the related objects in time order
+func (r *EventRecorder) timeline() EventTimeline {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	timeline := EventTimeline{Start: r.start, Events: make([]TimelineEvent, 0)}
+	for _, event := range r.events {
+		if !r.related[involvedKey(&event)] {
+			continue
+		}
+
+		timeline.Events = append(timeline.Events, TimelineEvent{
+			Time:      eventTime(&event),
+			Type:      event.Type,
+			Kind:      event.InvolvedObject.Kind,
+			Namespace: event.InvolvedObject.Namespace,
+			Name:      event.InvolvedObject.Name,
+			Reason:    event.Reason,
+			Message:   event.Message,
+			Count:     event.Count,
+		})
+	}
+
+	sort.SliceStable(timeline.Events, func(i, j int) bool {
+		if !timeline.Events[i].Time.Equal(timeline.Events[j].Time) {
+			return timeline.Events[i].Time.Before(timeline.Events[j].Time)
+		}
+		return timeline.Events[i].Kind+timeline.Events[i].Name < timeline.Events[j].Kind+timeline.Events[j].Name
+	})
+
+	return timeline
+}
+
+// ReportEvents writes the timeline to the test's artifact directory and, if the test failed, adds
+// it to the spec report. Nothing is reported for a spec that did not record events (e.g. it was
+// skipped).
+func (t *T) ReportEvents(ctx context.Context, timeline EventTimeline, failed bool) {
+	if timeline.Start.IsZero() {
+		return
+	}
+
+	file := filepath.Join(ArtifactDir(ctx, t.Workflow()), EventsFile)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err == nil {
+		if err := os.WriteFile(file, []byte(timeline.String()), 0644); err != nil {
+			GinkgoWriter.Printf("Failed to write the events of '%s': %v\n", t.Name(), err)
+		}
+	}
+
+	if failed {
+		AddReportEntry(fmt.Sprintf("Events for '%s'", t.Workflow().Name), timeline.String())
+	}
+}
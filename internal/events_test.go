@@ -0,0 +1,97 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+func TestEventTimeline(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+
+	r := &EventRecorder{
+		workflows: []*dwsv1alpha7.Workflow{{ObjectMeta: metav1.ObjectMeta{Name: "xfs", Namespace: "default"}}},
+		start:     start,
+		events:    make(map[types.UID]corev1.Event),
+		related:   map[string]bool{diagnosticKey("Workflow", "default", "xfs"): true},
+	}
+
+	event := func(uid, kind, name, reason string, at time.Time) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{UID: types.UID(uid), Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Namespace: "default", Name: name},
+			Reason:         reason,
+			Type:           corev1.EventTypeWarning,
+			LastTimestamp:  metav1.NewTime(at),
+			Count:          1,
+		}
+	}
+
+	r.record(event("2", "Workflow", "xfs", "Denied", start.Add(10*time.Second)))
+	r.record(event("3", "Workflow", "xfs", "Stale", start.Add(-time.Minute)))
+	r.record(event("4", "NnfStorage", "other-0", "Unrelated", start.Add(5*time.Second)))
+	r.record(event("5", "Node", "rabbit-node-1", "NodeNotReady", start.Add(5*time.Second)))
+
+	if r.unknown {
+		t.Errorf("expected events for objects not named after the workflow not to request a refresh")
+	}
+	if _, found := r.events["5"]; found {
+		t.Errorf("expected events for other kinds not to be kept")
+	}
+
+	r.record(event("1", "NnfStorage", "xfs-0", "FailedMount", start.Add(20*time.Second)))
+	if !r.unknown {
+		t.Errorf("expected events for unknown objects named after the workflow to request a refresh")
+	}
+
+	// The refresh finds the workflow's storage
+	r.related[diagnosticKey("NnfStorage", "default", "xfs-0")] = true
+
+	// A repeated event replaces the earlier version
+	repeated := event("1", "NnfStorage", "xfs-0", "FailedMount", start.Add(30*time.Second))
+	repeated.Count = 3
+	r.record(repeated)
+
+	r.prune()
+	if len(r.events) != 2 {
+		t.Errorf("expected unrelated events to be pruned, got %v", r.events)
+	}
+
+	timeline := r.timeline()
+	if len(timeline.Events) != 2 {
+		t.Fatalf("expected 2 events, got %v", timeline.Events)
+	}
+
+	if timeline.Events[0].Reason != "Denied" || timeline.Events[1].Reason != "FailedMount" {
+		t.Errorf("expected events in time order, got %v", timeline.Events)
+	}
+
+	if line := strings.Split(timeline.String(), "\n")[1]; !strings.Contains(line, "+30s") || !strings.Contains(line, "(x3)") {
+		t.Errorf("unexpected timeline line '%s'", line)
+	}
+}
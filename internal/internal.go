@@ -85,12 +85,23 @@ type T struct {
 
 	// Compute nodes that were assigned to the test. This is determined at test runtime.
 	computes *dwsv1alpha7.Computes
+
+	// Time taken to achieve each state executed by the test
+	timings []StateTiming
+
+	// Resources created for the test
+	resources ResourceCounts
+
+	// The first directive that failed to parse, if any. This is only permitted for tests that
+	// expect the workflow to be rejected.
+	invalidDirective string
 }
 
 func MakeTest(name string, directives ...string) *T {
 
 	// Extract a common set of labels from the directives
 	labels := make([]string, 0)
+	invalidDirective := ""
 	for _, directive := range directives {
 		if len(directive) == 0 {
 			continue
@@ -98,7 +109,12 @@ func MakeTest(name string, directives ...string) *T {
 
 		args, err := dwdparse.BuildArgsMap(directive)
 		if err != nil {
-			panic(fmt.Sprintf("Test '%s' failed to parse provided directive '%s'", name, directive))
+			// Defer the failure until the test options are known; a test may expect the
+			// directive to be rejected. See ExpectCreateRejected().
+			if invalidDirective == "" {
+				invalidDirective = directive
+			}
+			continue
 		}
 
 		labels = append(labels, args["command"])
@@ -109,10 +125,11 @@ func MakeTest(name string, directives ...string) *T {
 	}
 
 	t := &T{
-		name:       name,
-		directives: directives,
-		labels:     labels,
-		decorators: make([]interface{}, 0),
+		name:             name,
+		directives:       directives,
+		labels:           labels,
+		decorators:       make([]interface{}, 0),
+		invalidDirective: invalidDirective,
 	}
 	if len(directives) == 0 {
 		t.directives = []string{}
@@ -167,6 +184,10 @@ func (t *T) HardwareRequired() *T { t.options.hardwareRequired = true; return t
 func (t *T) Name() string { return t.name }
 
 func (t *T) Args() []interface{} {
+	if t.invalidDirective != "" && t.options.expectCreateRejected == nil {
+		panic(fmt.Sprintf("Test '%s' failed to parse provided directive '%s'", t.name, t.invalidDirective))
+	}
+
 	args := make([]interface{}, 0)
 
 	if len(t.labels) != 0 {
@@ -180,52 +201,126 @@ func (t *T) Args() []interface{} {
 	return args
 }
 
+// DuplicateTest runs count copies of the test case. Each copy is given a unique name, and every
+// name in the copy's directives, along with any references to those names, is made unique so
+// the copies can run alongside each other. Options that create resources (storage and container
+// profiles, persistent and global lustre, MGS pools) are cloned with unique names per copy.
 func DuplicateTest(t *T, count int) *T {
 
-	if len(t.directives) != 1 {
-		// To support more than one directive, we'd have to know how to copy
-		// directives over from the base test
-		panic("Duplicate can only take a test with one directive")
+	tests := make([]*T, count)
+	for index := 0; index < count; index++ {
+		tests[index] = t.duplicate(fmt.Sprintf("-%d", index))
 	}
 
-	if t.options.hasComplexOptions() {
-		// Duplicating a test with options is not possible since those
-		// options take parameters that are unique to the test and there
-		// is no simple way to port them to duplicate test cases
-		panic("Can not duplicate tests with options at this time")
+	t.options.duplicate = &TDuplicate{
+		t:     t,
+		tests: tests,
+		index: 0,
 	}
 
-	directive := t.directives[0]
+	return t
+}
+
+// duplicate returns a copy of the test with the suffix appended to the test name, the directive
+// names, and the names of any resources created by the test options.
+func (t *T) duplicate(suffix string) *T {
+	o := t.options
+
+	directives := make([]string, len(t.directives))
+	for index, directive := range t.directives {
+		directives[index] = o.duplicateDirective(directive, suffix)
+	}
+
+	dup := MakeTest(t.name+suffix, directives...)
+
+	dup.labels = append([]string{}, t.labels...)
+	dup.decorators = append([]interface{}{}, t.decorators...)
+	dup.options = o.clone(suffix)
+
+	dup.workflow.Spec.UserID = t.workflow.Spec.UserID
+	dup.workflow.Spec.GroupID = t.workflow.Spec.GroupID
+
+	return dup
+}
+
+// duplicateDirective appends the suffix to every name in the directive. This includes the
+// directive's own name, the storage names referenced by a container directive, and any
+// $DW_JOB_<name> or $DW_PERSISTENT_<name> references. Profiles and global lustre paths are
+// only renamed if the test options manage them.
+func (o *TOptions) duplicateDirective(directive, suffix string) string {
 	args, _ := dwdparse.BuildArgsMap(directive)
+	command := args["command"]
 
-	if args["command"] != "jobdw" {
-		panic("Can only duplicate jobdw's at this time")
+	fields := strings.Fields(directive)
+	for index, field := range fields {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			continue
+		}
+
+		switch {
+		case key == "name":
+			value += suffix
+		case key == "profile":
+			if o.storageProfile != nil && value == o.storageProfile.name && (command == "jobdw" || command == "create_persistent") {
+				value += suffix
+			}
+			if o.containerProfile != nil && value == o.containerProfile.name && command == "container" {
+				value += suffix
+			}
+		case strings.HasPrefix(key, "DW_JOB_") || strings.HasPrefix(key, "DW_PERSISTENT_"):
+			value += suffix
+		default:
+			value = renameStorageReference(value, suffix)
+			if o.globalLustre != nil {
+				value = o.globalLustre.renamePath(value, suffix)
+			}
+		}
+
+		fields[index] = key + "=" + value
 	}
 
-	// Duplicate the test, ensuring that both the test name and the
-	// #DW jobdw dw are unique
-	nameArg := "name=" + args["name"]
+	return strings.Join(fields, " ")
+}
 
-	tests := make([]*T, count)
-	for index := 0; index < count; index++ {
+// renameStorageReference appends the suffix to the storage name in a $DW_JOB_<name> or
+// $DW_PERSISTENT_<name> reference, keeping any path that follows the name.
+func renameStorageReference(value, suffix string) string {
+	for _, prefix := range []string{"$DW_JOB_", "$DW_PERSISTENT_"} {
+		if !strings.HasPrefix(value, prefix) {
+			continue
+		}
+
+		if index := strings.Index(value, "/"); index != -1 {
+			return value[:index] + suffix + value[index:]
+		}
+
+		return value + suffix
+	}
 
-		tests[index] = MakeTest(
-			fmt.Sprintf("%s-%d", t.name, index),
-			strings.Replace(directive, nameArg, fmt.Sprintf("%s-%d", nameArg, index), 1),
-		)
+	return value
+}
 
-		tests[index].decorators = t.decorators
-		tests[index].labels = t.labels
-		tests[index].options = t.options
+// renamePath renames a path on the global lustre file system for a duplicate. A global lustre
+// that is created from a persistent lustre instance is unique to each duplicate, so the suffix is
+// appended to the mount root. An existing lustre file system is shared between the duplicates, so
+// the path is instead placed in a directory unique to the duplicate to keep the copy_in/copy_out
+// files separate.
+func (g *TGlobalLustre) renamePath(path, suffix string) string {
+	if path != g.mountRoot && !strings.HasPrefix(path, g.mountRoot+"/") {
+		return path
 	}
 
-	t.options.duplicate = &TDuplicate{
-		t:     t,
-		tests: tests,
-		index: 0,
+	rest := strings.TrimPrefix(path, g.mountRoot)
+	if g.persistent == nil {
+		if rest == "" {
+			return path
+		}
+
+		return g.mountRoot + "/dup" + suffix + rest
 	}
 
-	return t
+	return g.mountRoot + suffix + rest
 }
 
 type iterator struct {
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDuplicateDataMovement(t *testing.T) {
+	test := DuplicateTest(
+		MakeTest("XFS with Data Movement",
+			"#DW jobdw type=xfs name=xfs-dm capacity=50GB profile=my-profile",
+			"#DW copy_in source=/lus/zenith/testuser/test.in destination=$DW_JOB_xfs-dm/",
+			"#DW copy_out profile=no-xattr source=$DW_JOB_xfs-dm/test.in destination=/lus/zenith/testuser/test.out").
+			WithPersistentLustre("xfs-dm-lustre").
+			WithGlobalLustreFromPersistentLustre("zenith", []string{"default"}).
+			WithStorageProfile().
+			WithPermissions(1000, 1001),
+		2,
+	)
+
+	itr := TestIterator([]*T{test})
+	first, second := itr.Next(), itr.Next()
+	if first == nil || second == nil || itr.Next() != nil {
+		t.Fatalf("expected exactly 2 duplicated tests")
+	}
+
+	expected := []string{
+		"#DW jobdw type=xfs name=xfs-dm-1 capacity=50GB profile=my-profile-1",
+		"#DW copy_in source=/lus/zenith-1/testuser/test.in destination=$DW_JOB_xfs-dm-1/",
+		"#DW copy_out profile=no-xattr source=$DW_JOB_xfs-dm-1/test.in destination=/lus/zenith-1/testuser/test.out",
+	}
+	if !reflect.DeepEqual(second.directives, expected) {
+		t.Errorf("unexpected directives:\n%v\nexpected:\n%v", second.directives, expected)
+	}
+
+	o := second.options
+	if second.Name() != "XFS with Data Movement-1" {
+		t.Errorf("unexpected name '%s'", second.Name())
+	}
+	if o.storageProfile.name != "my-profile-1" {
+		t.Errorf("unexpected storage profile '%s'", o.storageProfile.name)
+	}
+	if o.persistentLustre.name != "xfs-dm-lustre-1" {
+		t.Errorf("unexpected persistent lustre '%s'", o.persistentLustre.name)
+	}
+	if o.globalLustre.persistent != o.persistentLustre {
+		t.Errorf("global lustre does not reference the duplicated persistent lustre")
+	}
+	if o.globalLustre.name != "global-zenith-1" || o.globalLustre.mountRoot != "/lus/zenith-1" {
+		t.Errorf("unexpected global lustre '%s' @ '%s'", o.globalLustre.name, o.globalLustre.mountRoot)
+	}
+	if o.globalLustre.in != "/lus/zenith-1/testuser/test.in" || o.globalLustre.out != "/lus/zenith-1/testuser/*/test.out" {
+		t.Errorf("unexpected global lustre files '%s' '%s'", o.globalLustre.in, o.globalLustre.out)
+	}
+	if second.workflow.Spec.UserID != 1000 || second.workflow.Spec.GroupID != 1001 {
+		t.Errorf("permissions not duplicated")
+	}
+
+	// The original test must not be modified
+	if test.options.storageProfile.name != "my-profile" || first.options.storageProfile.name != "my-profile-0" {
+		t.Errorf("storage profile names are not unique")
+	}
+}
+
+func TestDuplicateContainers(t *testing.T) {
+	test := DuplicateTest(
+		MakeTest("GFS2 and Lustre with Containers",
+			"#DW jobdw name=local type=gfs2 capacity=100GB",
+			"#DW persistentdw name=persistent",
+			"#DW container name=containers profile=my-container DW_JOB_foo_local_storage=local DW_PERSISTENT_foo_persistent_storage=persistent").
+			WithPersistentLustre("persistent").
+			WithContainerProfile("example-success", nil),
+		1,
+	)
+
+	dup := TestIterator([]*T{test}).Next()
+
+	expected := []string{
+		"#DW jobdw name=local-0 type=gfs2 capacity=100GB",
+		"#DW persistentdw name=persistent-0",
+		"#DW container name=containers-0 profile=my-container-0 DW_JOB_foo_local_storage=local-0 DW_PERSISTENT_foo_persistent_storage=persistent-0",
+	}
+	if !reflect.DeepEqual(dup.directives, expected) {
+		t.Errorf("unexpected directives:\n%v\nexpected:\n%v", dup.directives, expected)
+	}
+
+	if dup.options.persistentLustre.name != "persistent-0" {
+		t.Errorf("unexpected persistent lustre '%s'", dup.options.persistentLustre.name)
+	}
+	if dup.options.containerProfile.name != "my-container-0" || dup.options.containerProfile.base != "example-success" {
+		t.Errorf("unexpected container profile %+v", dup.options.containerProfile)
+	}
+}
+
+func TestDuplicateExistingGlobalLustre(t *testing.T) {
+	test := DuplicateTest(
+		MakeTest("Lustre with Data Movement",
+			"#DW jobdw type=lustre name=lustre-dm capacity=50GB",
+			"#DW copy_in source=/lus/global/testuser/test.in destination=$DW_JOB_lustre-dm/",
+			"#DW copy_out source=$DW_JOB_lustre-dm/test.in destination=/lus/global/testuser/test.out").
+			WithGlobalLustre("/lus/global", "lushtx", "10.1.1.113@tcp", "default"),
+		2,
+	)
+
+	itr := TestIterator([]*T{test})
+	itr.Next()
+	second := itr.Next()
+
+	expected := []string{
+		"#DW jobdw type=lustre name=lustre-dm-1 capacity=50GB",
+		"#DW copy_in source=/lus/global/dup-1/testuser/test.in destination=$DW_JOB_lustre-dm-1/",
+		"#DW copy_out source=$DW_JOB_lustre-dm-1/test.in destination=/lus/global/dup-1/testuser/test.out",
+	}
+	if !reflect.DeepEqual(second.directives, expected) {
+		t.Errorf("unexpected directives:\n%v\nexpected:\n%v", second.directives, expected)
+	}
+
+	// The existing file system is shared, so only the LustreFileSystem resource is renamed
+	o := second.options
+	if o.globalLustre.name != "global-lushtx-1" || o.globalLustre.fsName != "lushtx" || o.globalLustre.mountRoot != "/lus/global" {
+		t.Errorf("unexpected global lustre '%s' (%s) @ '%s'", o.globalLustre.name, o.globalLustre.fsName, o.globalLustre.mountRoot)
+	}
+	if o.globalLustre.in != "/lus/global/dup-1/testuser/test.in" || o.globalLustre.out != "/lus/global/dup-1/testuser/test.out" {
+		t.Errorf("unexpected global lustre files '%s' '%s'", o.globalLustre.in, o.globalLustre.out)
+	}
+	if _, found := o.globalLustre.namespaces["default"]; !found {
+		t.Errorf("namespace 'default' not found in %v", o.globalLustre.namespaces)
+	}
+}
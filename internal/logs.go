@@ -0,0 +1,274 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/ginkgo/v2"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+// ClientsetContextKey is the context key for the suite's Kubernetes clientset, used to stream logs
+const ClientsetContextKey = "clientset"
+
+// maxReportedLogLines is the maximum number of log lines of each pod included in the report. The
+// complete logs are written to the test's artifact directory.
+const maxReportedLogLines = 200
+
+// ControllerLogSource is a controller whose logs are collected when a test fails. The pods are
+// those selected by the controller's Deployment or DaemonSet.
+type ControllerLogSource struct {
+	Name      string
+	Kind      string
+	Key       types.NamespacedName
+	Container string
+
+	// Only collect the logs of the pods on the Rabbits used by the workflow
+	RabbitsOnly bool
+}
+
+// ControllerLogSources are the controllers whose logs are collected when a test fails
+var ControllerLogSources = []ControllerLogSource{
+	{Name: "nnf-sos", Kind: "Deployment", Key: types.NamespacedName{Namespace: "nnf-system", Name: "nnf-controller-manager"}, Container: "manager"},
+	{Name: "dws", Kind: "Deployment", Key: types.NamespacedName{Namespace: "dws-system", Name: "dws-controller-manager"}, Container: "manager"},
+	{Name: "nnf-dm", Kind: "Deployment", Key: types.NamespacedName{Namespace: "nnf-dm-system", Name: "nnf-dm-manager-controller-manager"}, Container: "manager"},
+	{Name: "nnf-node-manager", Kind: "DaemonSet", Key: types.NamespacedName{Namespace: "nnf-system", Name: "nnf-node-manager"}, Container: "manager", RabbitsOnly: true},
+}
+
+// pods returns the pods of the controller, limited to the Rabbits if required
+func (source ControllerLogSource) pods(ctx context.Context, k8sClient client.Client, rabbits []string) ([]corev1.Pod, error) {
+	var selector *metav1.LabelSelector
+	switch source.Kind {
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := k8sClient.Get(ctx, source.Key, deployment); err != nil {
+			return nil, err
+		}
+		selector = deployment.Spec.Selector
+	case "DaemonSet":
+		daemonSet := &appsv1.DaemonSet{}
+		if err := k8sClient.Get(ctx, source.Key, daemonSet); err != nil {
+			return nil, err
+		}
+		selector = daemonSet.Spec.Selector
+	default:
+		return nil, fmt.Errorf("unsupported controller kind '%s'", source.Kind)
+	}
+
+	labels, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := &corev1.PodList{}
+	if err := k8sClient.List(ctx, pods, client.InNamespace(source.Key.Namespace), client.MatchingLabelsSelector{Selector: labels}); err != nil {
+		return nil, err
+	}
+
+	if !source.RabbitsOnly {
+		return pods.Items, nil
+	}
+
+	return slices.DeleteFunc(pods.Items, func(pod corev1.Pod) bool { return !slices.Contains(rabbits, pod.Spec.NodeName) }), nil
+}
+
+// container returns the name of the source's container in the pod, or the pod's first container
+func (source ControllerLogSource) container(pod *corev1.Pod) string {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == source.Container {
+			return container.Name
+		}
+	}
+
+	return pod.Spec.Containers[0].Name
+}
+
+// workflowRabbits returns the Rabbits allocated to the workflow by its Servers
+func workflowRabbits(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow) []string {
+	graph, _ := buildDiagnosticGraph(ctx, k8sClient, workflow)
+
+	rabbits := make([]string, 0)
+	for _, d := range graph.objects {
+		servers, ok := d.object.(*dwsv1alpha7.Servers)
+		if !ok {
+			continue
+		}
+
+		for _, allocationSet := range servers.Spec.AllocationSets {
+			for _, storage := range allocationSet.Storage {
+				if !slices.Contains(rabbits, storage.Name) {
+					rabbits = append(rabbits, storage.Name)
+				}
+			}
+		}
+	}
+
+	slices.Sort(rabbits)
+	return rabbits
+}
+
+// streamPodLogs streams the logs of the pod's container between the start and end times. The
+// logs of the previous instance of the container are included if it restarted within the window.
+func streamPodLogs(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod, container string, start, end time.Time) ([]string, error) {
+	lines := make([]string, 0)
+
+	previous := []bool{false}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == container && status.LastTerminationState.Terminated != nil &&
+			status.LastTerminationState.Terminated.FinishedAt.After(start) {
+			previous = []bool{true, false}
+		}
+	}
+
+	for _, prev := range previous {
+		stream, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			Container:  container,
+			Previous:   prev,
+			SinceTime:  &metav1.Time{Time: start},
+			Timestamps: true,
+		}).Stream(ctx)
+		if err != nil {
+			return lines, err
+		}
+
+		lines = append(lines, linesBefore(bufio.NewScanner(stream), end)...)
+		stream.Close()
+	}
+
+	return lines, nil
+}
+
+// linesBefore returns the scanned log lines with a timestamp no later than the end time. Lines
+// without a timestamp (e.g. continuations) are kept with the preceding line.
+func linesBefore(scanner *bufio.Scanner, end time.Time) []string {
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lines := make([]string, 0)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if timestamp, _, found := strings.Cut(line, " "); found {
+			if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil && t.After(end) {
+				break
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// filterLogLines returns the lines that mention any of the names. Object names of the workflow's
+// children are derived from the workflow name, so this includes the logs of its children too.
+func filterLogLines(lines []string, names []string) []string {
+	return slices.DeleteFunc(slices.Clone(lines), func(line string) bool {
+		return !slices.ContainsFunc(names, func(name string) bool { return strings.Contains(line, name) })
+	})
+}
+
+// ReportControllerLogs collects the logs of the controllers between the start and end of the spec,
+// writing them to the test's artifact directory and adding the lines that mention the test's
+// workflows to the spec report. The logs of the node managers are collected from the Rabbits used
+// by the workflows.
+func (t *T) ReportControllerLogs(ctx context.Context, k8sClient client.Client, start, end time.Time) {
+	clientset, ok := ctx.Value(ClientsetContextKey).(kubernetes.Interface)
+	if !ok {
+		AddReportEntry("Controller Logs", "Controller logs are unavailable without a clientset")
+		return
+	}
+
+	workflows := t.workflows()
+
+	names := make([]string, 0, len(workflows))
+	rabbits := make([]string, 0)
+	for _, workflow := range workflows {
+		names = append(names, workflow.Name)
+		for _, rabbit := range workflowRabbits(ctx, k8sClient, workflow) {
+			if !slices.Contains(rabbits, rabbit) {
+				rabbits = append(rabbits, rabbit)
+			}
+		}
+	}
+
+	dir := filepath.Join(ArtifactDir(ctx, t.Workflow()), "logs")
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		AddReportEntry("Controller Logs", fmt.Sprintf("Failed to create '%s': %v", dir, err))
+		return
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Logs from %s to %s, mentioning %v. Complete logs in file://%s\n",
+		start.Format(time.RFC3339), end.Format(time.RFC3339), names, dir)
+
+	for _, source := range ControllerLogSources {
+		pods, err := source.pods(ctx, k8sClient, rabbits)
+		if err != nil {
+			fmt.Fprintf(b, "\n--- %s: %v ---\n", source.Name, err)
+			continue
+		}
+
+		for index := range pods {
+			pod := &pods[index]
+			container := source.container(pod)
+
+			lines, err := streamPodLogs(ctx, clientset, pod, container, start, end)
+			if err != nil {
+				fmt.Fprintf(b, "\n--- %s %s/%s [%s]: %v ---\n", source.Name, pod.Namespace, pod.Name, container, err)
+				continue
+			}
+
+			file := filepath.Join(dir, fmt.Sprintf("%s-%s.log", source.Name, pod.Name))
+			if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+				fmt.Fprintf(b, "\n--- %s %s/%s: %v ---\n", source.Name, pod.Namespace, pod.Name, err)
+			}
+
+			filtered := filterLogLines(lines, names)
+			fmt.Fprintf(b, "\n--- %s %s/%s [%s] (%d of %d lines) ---\n", source.Name, pod.Namespace, pod.Name, container, len(filtered), len(lines))
+			if len(filtered) > maxReportedLogLines {
+				fmt.Fprintf(b, "... %d earlier lines in %s\n", len(filtered)-maxReportedLogLines, file)
+				filtered = filtered[len(filtered)-maxReportedLogLines:]
+			}
+			for _, line := range filtered {
+				fmt.Fprintln(b, line)
+			}
+		}
+	}
+
+	AddReportEntry(fmt.Sprintf("Controller Logs for '%s'", t.Workflow().Name), b.String())
+}
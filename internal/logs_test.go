@@ -0,0 +1,53 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"bufio"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControllerLogLines(t *testing.T) {
+	logs := strings.Join([]string{
+		"2026-10-17T10:00:01.000000000Z INFO Reconciling workflow {\"Workflow\": \"default/xfs\"}",
+		"2026-10-17T10:00:02.000000000Z INFO Reconciling storage {\"NnfStorage\": \"default/xfs-0\"}",
+		"  continuation of the previous line",
+		"2026-10-17T10:00:03.000000000Z INFO Reconciling workflow {\"Workflow\": \"default/lustre\"}",
+		"2026-10-17T10:05:00.000000000Z INFO Reconciling workflow {\"Workflow\": \"default/xfs\"}",
+	}, "\n")
+
+	end := time.Date(2026, 10, 17, 10, 1, 0, 0, time.UTC)
+	lines := linesBefore(bufio.NewScanner(strings.NewReader(logs)), end)
+	if len(lines) != 4 {
+		t.Fatalf("expected the 4 lines before the end of the spec, got %v", lines)
+	}
+
+	filtered := filterLogLines(lines, []string{"xfs"})
+	if len(filtered) != 2 || !strings.Contains(filtered[1], "xfs-0") {
+		t.Errorf("expected the 2 lines mentioning the workflow, got %v", filtered)
+	}
+
+	if !slices.Equal(lines, filterLogLines(lines, []string{"Reconciling", "continuation"})) {
+		t.Errorf("expected filtering to leave the original lines intact")
+	}
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TMatrix expands a template test over one or more dimensions, producing a test case for every
+// combination of dimension values. For example, the following produces eight test cases; one
+// for each file system type with and without a storage profile.
+//
+//	MakeMatrix("Simple", "#DW jobdw name=simple capacity=50GB").
+//		Across(Arg("type", "xfs", "gfs2", "lustre", "raw")).
+//		Across(Variants("profile",
+//			MatrixValue{Name: "default"},
+//			MatrixValue{Name: "storage-profile", Args: map[string]string{"profile": "simple-profile"}, Apply: (*T).WithStorageProfile},
+//		)).
+//		Tests()
+type TMatrix struct {
+	name       string
+	directives []string
+	dimensions []MatrixDimension
+	apply      []func(*T) *T
+}
+
+// MatrixDimension is a named set of values the matrix is expanded across.
+type MatrixDimension struct {
+	Name   string
+	Values []MatrixValue
+}
+
+// MatrixValue is one value of a dimension. Args are set on the first (template) directive,
+// Labels are added to the test, and Apply, if provided, is called to configure any additional
+// test options (e.g. (*T).WithStorageProfile).
+type MatrixValue struct {
+	Name   string
+	Args   map[string]string
+	Labels []string
+	Apply  func(*T) *T
+}
+
+// MakeMatrix creates a matrix from a template test. The first directive is the template that
+// dimension arguments are applied to; any other directives are included as-is.
+func MakeMatrix(name string, directives ...string) *TMatrix {
+	if len(directives) == 0 {
+		panic(fmt.Sprintf("Matrix '%s' requires a template directive", name))
+	}
+
+	return &TMatrix{name: name, directives: directives}
+}
+
+// Arg returns a dimension that sets the directive argument 'key' to each of the values.
+func Arg(key string, values ...string) MatrixDimension {
+	dimension := MatrixDimension{Name: key, Values: make([]MatrixValue, len(values))}
+	for index, value := range values {
+		dimension.Values[index] = MatrixValue{Name: value, Args: map[string]string{key: value}}
+	}
+
+	return dimension
+}
+
+// Variants returns a dimension made up of the provided values. This is useful for values that
+// change more than a single argument, such as applying a storage profile or a set of labels.
+func Variants(name string, values ...MatrixValue) MatrixDimension {
+	return MatrixDimension{Name: name, Values: values}
+}
+
+// LabelSets returns a dimension that applies each set of labels to a copy of the test.
+func LabelSets(name string, sets ...[]string) MatrixDimension {
+	dimension := MatrixDimension{Name: name, Values: make([]MatrixValue, len(sets))}
+	for index, labels := range sets {
+		dimension.Values[index] = MatrixValue{Name: strings.Join(labels, "-"), Labels: labels}
+	}
+
+	return dimension
+}
+
+// Across adds a dimension to the matrix.
+func (m *TMatrix) Across(dimension MatrixDimension) *TMatrix {
+	if len(dimension.Values) == 0 {
+		panic(fmt.Sprintf("Matrix '%s' dimension '%s' has no values", m.name, dimension.Name))
+	}
+
+	m.dimensions = append(m.dimensions, dimension)
+	return m
+}
+
+// With applies the provided options to every test in the matrix (e.g. (*T).Serialized).
+func (m *TMatrix) With(apply ...func(*T) *T) *TMatrix {
+	m.apply = append(m.apply, apply...)
+	return m
+}
+
+// Tests expands the matrix into the full set of test cases. Each test is named after the matrix
+// and its dimension values, and is given a "<dimension>-<value>" label for every value so that
+// slices of the matrix can be selected with a label filter.
+func (m *TMatrix) Tests() []*T {
+	tests := make([]*T, 0)
+
+	var expand func(depth int, values []MatrixValue)
+	expand = func(depth int, values []MatrixValue) {
+		if depth == len(m.dimensions) {
+			tests = append(tests, m.makeTest(values))
+			return
+		}
+
+		for _, value := range m.dimensions[depth].Values {
+			expand(depth+1, append(values[:depth:depth], value))
+		}
+	}
+
+	expand(0, make([]MatrixValue, 0, len(m.dimensions)))
+
+	return tests
+}
+
+// makeTest builds the template test for the combination of values and then duplicates it with
+// a suffix made from the value names, so the directive names and any resources created by the
+// test options are unique to this combination.
+func (m *TMatrix) makeTest(values []MatrixValue) *T {
+	directives := append([]string{}, m.directives...)
+
+	suffix := ""
+	labels := []string{"matrix"}
+	for index, value := range values {
+		for key, arg := range value.Args {
+			directives[0] = setDirectiveArg(directives[0], key, arg)
+		}
+
+		suffix += "-" + matrixName(value.Name)
+		labels = append(labels, matrixName(m.dimensions[index].Name)+"-"+matrixName(value.Name))
+		labels = append(labels, value.Labels...)
+	}
+
+	t := MakeTest(m.name, directives...).WithLabels(labels...)
+	for _, value := range values {
+		if value.Apply != nil {
+			t = value.Apply(t)
+		}
+	}
+
+	for _, apply := range m.apply {
+		t = apply(t)
+	}
+
+	return t.duplicate(suffix)
+}
+
+// setDirectiveArg replaces the value of 'key' in the directive, or appends the argument if the
+// directive does not have one.
+func setDirectiveArg(directive, key, value string) string {
+	fields := strings.Fields(directive)
+	for index, field := range fields {
+		if k, _, found := strings.Cut(field, "="); found && k == key {
+			fields[index] = key + "=" + value
+			return strings.Join(fields, " ")
+		}
+	}
+
+	return strings.Join(append(fields, key+"="+value), " ")
+}
+
+var matrixNameRegex = regexp.MustCompile("[^a-z0-9]+")
+
+// matrixName converts a dimension or value name into a form that can be used in a directive
+// name and as a ginkgo label.
+func matrixName(name string) string {
+	return strings.Trim(matrixNameRegex.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMatrix(t *testing.T) {
+	tests := MakeMatrix("Simple", "#DW jobdw name=simple capacity=50GB").
+		Across(Arg("type", "xfs", "gfs2", "lustre", "raw")).
+		Across(Variants("profile",
+			MatrixValue{Name: "default"},
+			MatrixValue{Name: "storage-profile", Args: map[string]string{"profile": "simple-profile"}, Apply: (*T).WithStorageProfile},
+		)).
+		Tests()
+
+	if len(tests) != 8 {
+		t.Fatalf("expected 8 tests, found %d", len(tests))
+	}
+
+	names := make(map[string]bool)
+	for _, test := range tests {
+		if names[test.WorkflowName()] {
+			t.Errorf("duplicate workflow name '%s'", test.WorkflowName())
+		}
+		names[test.WorkflowName()] = true
+	}
+
+	test := tests[3]
+	if test.Name() != "Simple-gfs2-storage-profile" {
+		t.Errorf("unexpected name '%s'", test.Name())
+	}
+
+	expected := "#DW jobdw name=simple-gfs2-storage-profile capacity=50GB type=gfs2 profile=simple-profile-gfs2-storage-profile"
+	if test.directives[0] != expected {
+		t.Errorf("unexpected directive '%s', expected '%s'", test.directives[0], expected)
+	}
+
+	if test.options.storageProfile == nil || test.options.storageProfile.name != "simple-profile-gfs2-storage-profile" {
+		t.Errorf("storage profile not configured: %+v", test.options.storageProfile)
+	}
+
+	for _, label := range []string{"matrix", "gfs2", "type-gfs2", "profile-storage-profile"} {
+		if !slices.Contains(test.labels, label) {
+			t.Errorf("label '%s' not found in %v", label, test.labels)
+		}
+	}
+
+	if tests[0].options.storageProfile != nil {
+		t.Errorf("default variant should not have a storage profile")
+	}
+}
+
+func TestMatrixLabelSets(t *testing.T) {
+	tests := MakeMatrix("Labelled", "#DW jobdw type=xfs name=labelled capacity=50GB").
+		Across(LabelSets("set", []string{"a", "b"}, []string{"c"})).
+		With((*T).Serialized).
+		Tests()
+
+	if len(tests) != 2 {
+		t.Fatalf("expected 2 tests, found %d", len(tests))
+	}
+
+	if !slices.Contains(tests[0].labels, "a") || !slices.Contains(tests[0].labels, "set-a-b") {
+		t.Errorf("unexpected labels %v", tests[0].labels)
+	}
+
+	if len(tests[1].decorators) != 1 {
+		t.Errorf("expected serial decorator on every test")
+	}
+}
+
+func TestAbortInEachState(t *testing.T) {
+	tests := AbortInEachState(MakeTest("Abort", "#DW jobdw type=xfs name=abort capacity=50GB"))
+	if len(tests) != len(AbortStates) {
+		t.Fatalf("expected %d tests, got %d", len(AbortStates), len(tests))
+	}
+
+	for index, test := range tests {
+		state := AbortStates[index]
+		if test.options.abortAfter == nil || test.options.abortAfter.state != state {
+			t.Errorf("test '%s' does not abort after %s", test.Name(), state)
+		}
+		if !slices.Contains(test.labels, "abort-"+matrixName(string(state))) {
+			t.Errorf("test '%s' missing abort label: %v", test.Name(), test.labels)
+		}
+	}
+
+	if tests[1].Name() != "Abort-abort-setup" || tests[1].directives[0] != "#DW jobdw type=xfs name=abort-abort-setup capacity=50GB" {
+		t.Errorf("unexpected test '%s': %v", tests[1].Name(), tests[1].directives)
+	}
+
+	matrix := MakeMatrix("Abort", "#DW jobdw type=xfs name=abort capacity=50GB").Across(AbortDimension()).Tests()
+	if len(matrix) != len(AbortStates) || matrix[len(matrix)-1].options.abortAfter.state != AbortStates[len(AbortStates)-1] {
+		t.Errorf("unexpected abort matrix")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic when aborting after Teardown")
+		}
+	}()
+	MakeTest("Abort", "#DW jobdw type=xfs name=abort capacity=50GB").AbortAfter("Teardown")
+}
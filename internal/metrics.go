@@ -0,0 +1,214 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+)
+
+// MetricsEnvVar selects the metrics file when the `-metrics` flag is not provided
+const MetricsEnvVar = "NNF_TEST_METRICS"
+
+// ResourcesReportEntry is the name of the report entry holding the resources created for a test
+const ResourcesReportEntry = "Resources"
+
+// ResourceCounts are the number of resources created for a test
+type ResourceCounts struct {
+	HelperPods        int `json:"helperPods"`
+	StorageProfiles   int `json:"storageProfiles"`
+	ContainerProfiles int `json:"containerProfiles"`
+}
+
+func (r ResourceCounts) add(other ResourceCounts) ResourceCounts {
+	return ResourceCounts{
+		HelperPods:        r.HelperPods + other.HelperPods,
+		StorageProfiles:   r.StorageProfiles + other.StorageProfiles,
+		ContainerProfiles: r.ContainerProfiles + other.ContainerProfiles,
+	}
+}
+
+// reportResources attaches the resources created for the test to the spec report
+func (t *T) reportResources() {
+	if t.resources != (ResourceCounts{}) {
+		AddReportEntry(ResourcesReportEntry, t.resources, ReportEntryVisibilityNever)
+	}
+}
+
+// StateDurationBuckets are the upper bounds, in seconds, of the state duration histogram buckets
+var StateDurationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// fsTypes are the test labels that name a file system type
+var fsTypes = []string{"xfs", "gfs2", "lustre", "raw"}
+
+// fsType returns the file system type(s) of a test from its labels
+func fsType(labels []string) string {
+	types := make([]string, 0)
+	for _, label := range labels {
+		if slices.Contains(fsTypes, label) && !slices.Contains(types, label) {
+			types = append(types, label)
+		}
+	}
+
+	if len(types) == 0 {
+		return "none"
+	}
+
+	sort.Strings(types)
+	return strings.Join(types, ",")
+}
+
+// labelValue escapes a label value per the Prometheus text format
+func labelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+type histogram struct {
+	labels  string
+	buckets []int
+	count   int
+	sum     float64
+}
+
+func (h *histogram) observe(seconds float64) {
+	for index, bound := range StateDurationBuckets {
+		if seconds <= bound {
+			h.buckets[index]++
+		}
+	}
+
+	h.count++
+	h.sum += seconds
+}
+
+// WriteMetrics writes the results in the Prometheus text format, as parsed by the node-exporter
+// textfile collector. That format has no notion of the OpenMetrics `_total` suffix, so counter
+// families are declared with the full sample name, nor of the `# UNIT` and `# EOF` lines. The
+// metrics are
+//
+//	nnf_integration_test_result_total{test,fs_type,labels,result}  Tests by result (passed, failed, skipped, ...)
+//	nnf_integration_test_state_duration_seconds{state,fs_type,labels}  Histogram of the latency of each state
+//	nnf_integration_test_helper_pods_total  Helper pods created
+//	nnf_integration_test_profiles_total{kind}  Storage and container profiles created
+func WriteMetrics(file string, results *Results) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it so the collector never reads a partial file
+	temp := file + ".tmp"
+	f, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+
+	if err := writeMetrics(f, results); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp, file)
+}
+
+func writeMetrics(w io.Writer, results *Results) error {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "# TYPE nnf_integration_test_result_total counter\n")
+	fmt.Fprintf(b, "# HELP nnf_integration_test_result_total Integration tests by result.\n")
+
+	histograms := make(map[string]*histogram)
+	resources := ResourceCounts{}
+	for _, result := range results.Tests {
+		labels := append([]string{}, result.Labels...)
+		sort.Strings(labels)
+
+		common := fmt.Sprintf(`fs_type="%s",labels="%s"`, labelValue(fsType(labels)), labelValue(strings.Join(labels, ",")))
+		fmt.Fprintf(b, "nnf_integration_test_result_total{test=\"%s\",%s,result=\"%s\"} 1\n", labelValue(result.Name), common, labelValue(result.State))
+
+		for index := range result.Timings {
+			timing := &result.Timings[index]
+
+			key := fmt.Sprintf(`state="%s",%s`, timing.State, common)
+			if _, found := histograms[key]; !found {
+				histograms[key] = &histogram{labels: key, buckets: make([]int, len(StateDurationBuckets))}
+			}
+
+			histograms[key].observe(timing.Latency().Seconds())
+		}
+
+		resources = resources.add(result.Resources)
+	}
+
+	keys := make([]string, 0, len(histograms))
+	for key := range histograms {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return stateOrder(histograms[keys[i]].labels) < stateOrder(histograms[keys[j]].labels) ||
+			(stateOrder(histograms[keys[i]].labels) == stateOrder(histograms[keys[j]].labels) && keys[i] < keys[j])
+	})
+
+	fmt.Fprintf(b, "# TYPE nnf_integration_test_state_duration_seconds histogram\n")
+	fmt.Fprintf(b, "# HELP nnf_integration_test_state_duration_seconds Time for a workflow to achieve a state.\n")
+	for _, key := range keys {
+		h := histograms[key]
+		for index, bound := range StateDurationBuckets {
+			fmt.Fprintf(b, "nnf_integration_test_state_duration_seconds_bucket{%s,le=\"%s\"} %d\n", h.labels, strconv.FormatFloat(bound, 'f', -1, 64), h.buckets[index])
+		}
+		fmt.Fprintf(b, "nnf_integration_test_state_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", h.labels, h.count)
+		fmt.Fprintf(b, "nnf_integration_test_state_duration_seconds_count{%s} %d\n", h.labels, h.count)
+		fmt.Fprintf(b, "nnf_integration_test_state_duration_seconds_sum{%s} %s\n", h.labels, strconv.FormatFloat(h.sum, 'f', 3, 64))
+	}
+
+	fmt.Fprintf(b, "# TYPE nnf_integration_test_helper_pods_total counter\n")
+	fmt.Fprintf(b, "# HELP nnf_integration_test_helper_pods_total Helper pods created by the tests.\n")
+	fmt.Fprintf(b, "nnf_integration_test_helper_pods_total %d\n", resources.HelperPods)
+
+	fmt.Fprintf(b, "# TYPE nnf_integration_test_profiles_total counter\n")
+	fmt.Fprintf(b, "# HELP nnf_integration_test_profiles_total Profiles created by the tests.\n")
+	fmt.Fprintf(b, "nnf_integration_test_profiles_total{kind=\"storage\"} %d\n", resources.StorageProfiles)
+	fmt.Fprintf(b, "nnf_integration_test_profiles_total{kind=\"container\"} %d\n", resources.ContainerProfiles)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// stateOrder sorts the histograms by the workflow state in their labels
+func stateOrder(labels string) int {
+	for index, state := range DefaultStates {
+		if strings.HasPrefix(labels, fmt.Sprintf(`state="%s"`, state)) {
+			return index
+		}
+	}
+
+	return len(DefaultStates)
+}
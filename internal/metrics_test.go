@@ -0,0 +1,137 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	results := &Results{
+		Tests: []TestResult{
+			{
+				Name:   "XFS",
+				Labels: []string{"xfs", "simple"},
+				State:  "passed",
+				Timings: StateTimings{
+					{State: dwsv1alpha7.StateSetup, Elapsed: 20 * time.Second},
+					{State: dwsv1alpha7.StateTeardown, Duration: 3 * time.Second},
+				},
+				Resources: ResourceCounts{HelperPods: 2, StorageProfiles: 1},
+			},
+			{
+				Name:      `Quote "Test"`,
+				Labels:    []string{"xfs", "simple"},
+				State:     "failed",
+				Timings:   StateTimings{{State: dwsv1alpha7.StateSetup, Elapsed: 90 * time.Second}},
+				Resources: ResourceCounts{ContainerProfiles: 1},
+			},
+		},
+	}
+
+	file := filepath.Join(t.TempDir(), "metrics", "nnf.prom")
+	if err := WriteMetrics(file, results); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	metrics := string(data)
+
+	common := `fs_type="xfs",labels="simple,xfs"`
+	for _, expected := range []string{
+		`nnf_integration_test_result_total{test="XFS",` + common + `,result="passed"} 1`,
+		`nnf_integration_test_result_total{test="Quote \"Test\"",` + common + `,result="failed"} 1`,
+		`nnf_integration_test_state_duration_seconds_bucket{state="Setup",` + common + `,le="30"} 1`,
+		`nnf_integration_test_state_duration_seconds_bucket{state="Setup",` + common + `,le="120"} 2`,
+		`nnf_integration_test_state_duration_seconds_bucket{state="Setup",` + common + `,le="+Inf"} 2`,
+		`nnf_integration_test_state_duration_seconds_count{state="Setup",` + common + `} 2`,
+		`nnf_integration_test_state_duration_seconds_sum{state="Setup",` + common + `} 110.000`,
+		`nnf_integration_test_state_duration_seconds_bucket{state="Teardown",` + common + `,le="5"} 1`,
+		`nnf_integration_test_helper_pods_total 2`,
+		`nnf_integration_test_profiles_total{kind="storage"} 1`,
+		`nnf_integration_test_profiles_total{kind="container"} 1`,
+	} {
+		if !strings.Contains(metrics, expected+"\n") {
+			t.Errorf("expected metric '%s' in\n%s", expected, metrics)
+		}
+	}
+
+	// The OpenMetrics only lines are rejected by the node-exporter textfile collector
+	if strings.Contains(metrics, "# UNIT") || strings.Contains(metrics, "# EOF") {
+		t.Errorf("unexpected OpenMetrics lines in\n%s", metrics)
+	}
+
+	if strings.Index(metrics, `state="Setup"`) > strings.Index(metrics, `state="Teardown"`) {
+		t.Errorf("expected histograms in state order")
+	}
+
+	// The textfile collector parses the Prometheus text format, where each family must be typed by
+	// the name of its samples
+	families, err := (&expfmt.TextParser{}).TextToMetricFamilies(strings.NewReader(metrics))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	for name, expected := range map[string]struct {
+		metricType dto.MetricType
+		count      int
+	}{
+		"nnf_integration_test_result_total":           {dto.MetricType_COUNTER, 2},
+		"nnf_integration_test_state_duration_seconds": {dto.MetricType_HISTOGRAM, 2},
+		"nnf_integration_test_helper_pods_total":      {dto.MetricType_COUNTER, 1},
+		"nnf_integration_test_profiles_total":         {dto.MetricType_COUNTER, 2},
+	} {
+		family, found := families[name]
+		if !found {
+			t.Errorf("expected family '%s' in %v", name, families)
+			continue
+		}
+		if family.GetType() != expected.metricType || len(family.GetMetric()) != expected.count {
+			t.Errorf("family '%s': expected %d %s metrics, got %d %s", name, expected.count, expected.metricType, len(family.GetMetric()), family.GetType())
+		}
+	}
+	if len(families) != 4 {
+		t.Errorf("expected 4 families, got %d", len(families))
+	}
+}
+
+func TestFsType(t *testing.T) {
+	for labels, expected := range map[string]string{
+		"simple,xfs":       "xfs",
+		"lustre,gfs2,xfs":  "gfs2,lustre,xfs",
+		"container,simple": "none",
+	} {
+		if actual := fsType(strings.Split(labels, ",")); actual != expected {
+			t.Errorf("labels '%s': expected '%s', got '%s'", labels, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"slices"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+// TNodeSelection describes the subset of the system a test runs on. Nil/zero values select
+// every node.
+type TNodeSelection struct {
+	computeCount      int      // Maximum number of computes to assign
+	rabbits           []string // Rabbits that may be used for storage
+	computesOnRabbits []string // Rabbits whose computes may be assigned
+}
+
+// SelectedNodes are the computes and Rabbits chosen from the system configuration for a test.
+type SelectedNodes struct {
+	// Computes are the names of the computes assigned to the workflow
+	Computes []string
+
+	// Rabbits are the storage nodes that may be used for storage, in system configuration order
+	Rabbits []SelectedRabbit
+}
+
+// SelectedRabbit is a storage node along with the number of selected computes attached to it.
+type SelectedRabbit struct {
+	Name     string
+	Computes int
+}
+
+// selectNodes chooses the computes and Rabbits for a test from the system configuration using
+// the ComputesAccess topology, so that the computes are attached to the chosen Rabbits. Computes
+// are taken from each eligible Rabbit in turn so that a limited count is spread across Rabbits.
+// When Rabbits are not explicitly selected, storage is restricted to the Rabbits attached to the
+// selected computes. The selection is deterministic for a given system configuration.
+func selectNodes(systemConfig *dwsv1alpha7.SystemConfiguration, s *TNodeSelection) (SelectedNodes, error) {
+	selected := SelectedNodes{Computes: make([]string, 0), Rabbits: make([]SelectedRabbit, 0)}
+
+	if s == nil {
+		s = &TNodeSelection{}
+	}
+
+	nodes := make(map[string]*dwsv1alpha7.SystemConfigurationStorageNode)
+	for index := range systemConfig.Spec.StorageNodes {
+		node := &systemConfig.Spec.StorageNodes[index]
+		nodes[node.Name] = node
+	}
+
+	for _, name := range append(append([]string{}, s.rabbits...), s.computesOnRabbits...) {
+		if _, found := nodes[name]; !found {
+			return selected, fmt.Errorf("rabbit '%s' not found in the system configuration", name)
+		}
+	}
+
+	// Computes are chosen from the Rabbits named by WithComputesOnRabbits(), falling back to
+	// those named by WithRabbits() and finally every Rabbit.
+	computeRabbits := s.computesOnRabbits
+	if len(computeRabbits) == 0 {
+		computeRabbits = s.rabbits
+	}
+	if len(computeRabbits) == 0 {
+		for _, node := range systemConfig.Spec.StorageNodes {
+			computeRabbits = append(computeRabbits, node.Name)
+		}
+	}
+
+	available := 0
+	for _, name := range computeRabbits {
+		available += len(nodes[name].ComputesAccess)
+	}
+
+	count := available
+	if s.computeCount > 0 {
+		if s.computeCount > available {
+			return selected, fmt.Errorf("requested %d computes but only %d are attached to rabbits %v", s.computeCount, available, computeRabbits)
+		}
+		count = s.computeCount
+	}
+
+	attached := make(map[string]int)
+	for index := 0; len(selected.Computes) < count; index++ {
+		for _, name := range computeRabbits {
+			access := nodes[name].ComputesAccess
+			if index < len(access) && len(selected.Computes) < count {
+				selected.Computes = append(selected.Computes, access[index].Name)
+				attached[name]++
+			}
+		}
+	}
+
+	// Storage is placed on the Rabbits named by WithRabbits() or, if only the computes were
+	// limited, the Rabbits attached to the selected computes.
+	for _, node := range systemConfig.Spec.StorageNodes {
+		switch {
+		case len(s.rabbits) != 0:
+			if !slices.Contains(s.rabbits, node.Name) {
+				continue
+			}
+		case s.computeCount != 0 || len(s.computesOnRabbits) != 0:
+			if attached[node.Name] == 0 {
+				continue
+			}
+		}
+
+		selected.Rabbits = append(selected.Rabbits, SelectedRabbit{Name: node.Name, Computes: attached[node.Name]})
+	}
+
+	return selected, nil
+}
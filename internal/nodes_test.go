@@ -0,0 +1,104 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+// makeSystemConfiguration returns a system of Rabbits named rabbit-0..N, each with the given
+// number of computes named <rabbit>-compute-M.
+func makeSystemConfiguration(rabbits, computes int) *dwsv1alpha7.SystemConfiguration {
+	systemConfig := &dwsv1alpha7.SystemConfiguration{}
+	for r := 0; r < rabbits; r++ {
+		node := dwsv1alpha7.SystemConfigurationStorageNode{Type: "Rabbit", Name: fmt.Sprintf("rabbit-%d", r)}
+		for c := 0; c < computes; c++ {
+			node.ComputesAccess = append(node.ComputesAccess, dwsv1alpha7.SystemConfigurationComputeNodeReference{
+				Name:  fmt.Sprintf("%s-compute-%d", node.Name, c),
+				Index: c,
+			})
+		}
+		systemConfig.Spec.StorageNodes = append(systemConfig.Spec.StorageNodes, node)
+	}
+
+	return systemConfig
+}
+
+func TestSelectNodes(t *testing.T) {
+	systemConfig := makeSystemConfiguration(3, 4)
+
+	tests := []struct {
+		name      string
+		selection *TNodeSelection
+		computes  []string
+		rabbits   []SelectedRabbit
+	}{
+		{
+			name:      "compute count",
+			selection: &TNodeSelection{computeCount: 2},
+			computes:  []string{"rabbit-0-compute-0", "rabbit-1-compute-0"},
+			rabbits:   []SelectedRabbit{{"rabbit-0", 1}, {"rabbit-1", 1}},
+		},
+		{
+			name:      "rabbits",
+			selection: &TNodeSelection{rabbits: []string{"rabbit-2"}, computeCount: 3},
+			computes:  []string{"rabbit-2-compute-0", "rabbit-2-compute-1", "rabbit-2-compute-2"},
+			rabbits:   []SelectedRabbit{{"rabbit-2", 3}},
+		},
+		{
+			name:      "computes on other rabbits",
+			selection: &TNodeSelection{rabbits: []string{"rabbit-0"}, computesOnRabbits: []string{"rabbit-1"}, computeCount: 1},
+			computes:  []string{"rabbit-1-compute-0"},
+			rabbits:   []SelectedRabbit{{"rabbit-0", 0}},
+		},
+	}
+
+	for _, test := range tests {
+		selected, err := selectNodes(systemConfig, test.selection)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(selected.Computes, test.computes) {
+			t.Errorf("%s: unexpected computes %v, expected %v", test.name, selected.Computes, test.computes)
+		}
+		if !reflect.DeepEqual(selected.Rabbits, test.rabbits) {
+			t.Errorf("%s: unexpected rabbits %v, expected %v", test.name, selected.Rabbits, test.rabbits)
+		}
+	}
+
+	// No selection uses the whole system
+	selected, _ := selectNodes(systemConfig, nil)
+	if len(selected.Computes) != 12 || len(selected.Rabbits) != 3 {
+		t.Errorf("expected the whole system, found %v", selected)
+	}
+
+	if _, err := selectNodes(systemConfig, &TNodeSelection{rabbits: []string{"rabbit-9"}}); err == nil {
+		t.Errorf("expected an error for an unknown rabbit")
+	}
+	if _, err := selectNodes(systemConfig, &TNodeSelection{rabbits: []string{"rabbit-0"}, computeCount: 5}); err == nil {
+		t.Errorf("expected an error for too many computes")
+	}
+}
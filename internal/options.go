@@ -22,12 +22,16 @@ package internal
 import (
 	"context"
 	"fmt"
+	"maps"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
 
 	"go.openly.dev/pointy"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -45,26 +49,134 @@ import (
 // TOptions lets you configure things prior to a test running or during test
 // execution. Nil values represent no configuration of that type.
 type TOptions struct {
-	stopAfter           *TStopAfter
-	delayInState        []TDelayInState
-	expectError         *TExpectError
-	storageProfile      *TStorageProfile
-	containerProfile    *TContainerProfile
-	persistentLustre    *TPersistentLustre
-	mgsPool             *TMgsPool
-	globalLustre        *TGlobalLustre
-	cleanupPersistent   *TCleanupPersistentInstance
-	duplicate           *TDuplicate
-	hardwareRequired    bool
-	lowTimeout          time.Duration
-	highTimeout         time.Duration
-	highTimeoutStates   []dwsv1alpha7.WorkflowState
-	useExternalComputes bool
-}
-
-// Complex options that can not be duplicated
-func (o *TOptions) hasComplexOptions() bool {
-	return o.storageProfile != nil || o.containerProfile != nil || o.persistentLustre != nil || o.globalLustre != nil || o.cleanupPersistent != nil
+	stopAfter            *TStopAfter
+	abortAfter           *TAbortAfter
+	delayInState         []TDelayInState
+	stateHooks           []TStateHook
+	states               []TState
+	expectError          *TExpectError
+	expectCreateRejected *regexp.Regexp
+	expectTransient      *TExpectTransient
+	rejectedTransitions  []TExpectTransitionRejected
+	storageProfile       *TStorageProfile
+	containerProfile     *TContainerProfile
+	persistentLustre     *TPersistentLustre
+	mgsPool              *TMgsPool
+	globalLustre         *TGlobalLustre
+	cleanupPersistent    *TCleanupPersistentInstance
+	duplicate            *TDuplicate
+	hardwareRequired     bool
+	lowTimeout           time.Duration
+	highTimeout          time.Duration
+	highTimeoutStates    []dwsv1alpha7.WorkflowState
+	stateTimeouts        map[dwsv1alpha7.WorkflowState]time.Duration
+	useExternalComputes  bool
+	nodes                *TNodeSelection
+	placementPolicy      PlacementPolicy
+	scenario             *TScenario
+	soak                 *SoakPolicy
+}
+
+// clone returns a copy of the options for a duplicated test. Options that create resources
+// are given unique names by appending the suffix so the copies do not collide with each other.
+func (o *TOptions) clone(suffix string) TOptions {
+	dup := *o
+	dup.duplicate = nil
+	dup.delayInState = append([]TDelayInState{}, o.delayInState...)
+	dup.stateHooks = append([]TStateHook{}, o.stateHooks...)
+	dup.rejectedTransitions = append([]TExpectTransitionRejected{}, o.rejectedTransitions...)
+	if o.states != nil {
+		dup.states = append([]TState{}, o.states...)
+	}
+	dup.highTimeoutStates = append([]dwsv1alpha7.WorkflowState{}, o.highTimeoutStates...)
+	dup.stateTimeouts = maps.Clone(o.stateTimeouts)
+
+	if o.mgsPool != nil {
+		dup.mgsPool = &TMgsPool{name: o.mgsPool.name + suffix, count: o.mgsPool.count}
+	}
+
+	if o.storageProfile != nil {
+		storageProfile := *o.storageProfile
+		storageProfile.name += suffix
+		if o.mgsPool != nil && storageProfile.externalMgs == "pool:"+o.mgsPool.name {
+			storageProfile.externalMgs = "pool:" + dup.mgsPool.name
+		}
+		dup.storageProfile = &storageProfile
+	}
+
+	if o.containerProfile != nil {
+		containerProfile := *o.containerProfile
+		containerProfile.name += suffix
+		dup.containerProfile = &containerProfile
+	}
+
+	if o.persistentLustre != nil {
+		dup.persistentLustre = &TPersistentLustre{
+			name:     o.persistentLustre.name + suffix,
+			capacity: o.persistentLustre.capacity,
+		}
+	}
+
+	if o.globalLustre != nil {
+		globalLustre := *o.globalLustre
+		globalLustre.name += suffix
+		globalLustre.in = o.globalLustre.renamePath(o.globalLustre.in, suffix)
+		globalLustre.out = o.globalLustre.renamePath(o.globalLustre.out, suffix)
+		if o.globalLustre.persistent != nil {
+			globalLustre.mountRoot += suffix
+			globalLustre.persistent = dup.persistentLustre
+		}
+		dup.globalLustre = &globalLustre
+	}
+
+	if o.cleanupPersistent != nil {
+		dup.cleanupPersistent = &TCleanupPersistentInstance{name: o.cleanupPersistent.name + suffix}
+	}
+
+	if o.expectTransient != nil {
+		expectTransient := *o.expectTransient
+		expectTransient.injected = nil
+		names := o.renamedObjects(&dup)
+		expectTransient.faults = make([]TransientFault, len(o.expectTransient.faults))
+		for index, fault := range o.expectTransient.faults {
+			expectTransient.faults[index] = fault.Clone(names)
+		}
+		dup.expectTransient = &expectTransient
+	}
+
+	if o.scenario != nil {
+		dup.scenario = o.scenario.clone(suffix)
+	}
+
+	if o.nodes != nil {
+		dup.nodes = &TNodeSelection{
+			computeCount:      o.nodes.computeCount,
+			rabbits:           slices.Clone(o.nodes.rabbits),
+			computesOnRabbits: slices.Clone(o.nodes.computesOnRabbits),
+		}
+	}
+
+	return dup
+}
+
+// renamedObjects maps the names of the objects managed by the options to their names in the
+// duplicated options
+func (o *TOptions) renamedObjects(dup *TOptions) map[string]string {
+	names := make(map[string]string)
+	if o.storageProfile != nil {
+		names[o.storageProfile.name] = dup.storageProfile.name
+	}
+	if o.containerProfile != nil {
+		names[o.containerProfile.name] = dup.containerProfile.name
+	}
+	if o.persistentLustre != nil {
+		names[o.persistentLustre.name] = dup.persistentLustre.name
+	}
+	if o.mgsPool != nil {
+		names[o.mgsPool.name] = dup.mgsPool.name
+	}
+
+	return names
 }
 
 type TStopAfter struct {
@@ -77,6 +189,21 @@ func (t *T) StopAfter(state dwsv1alpha7.WorkflowState) *T {
 	return t
 }
 
+type TAbortAfter struct {
+	state dwsv1alpha7.WorkflowState
+}
+
+// AbortAfter moves the workflow straight to Teardown once the state is reached, skipping the
+// remaining states, and then verifies the teardown is clean. See VerifyCleanTeardown().
+func (t *T) AbortAfter(state dwsv1alpha7.WorkflowState) *T {
+	if !slices.Contains(AbortStates, state) {
+		panic(fmt.Sprintf("Test '%s' cannot abort after state %s", t.name, state))
+	}
+
+	t.options.abortAfter = &TAbortAfter{state: state}
+	return t.WithLabels("abort", "abort-"+matrixName(string(state)))
+}
+
 type TDelayInState struct {
 	state    dwsv1alpha7.WorkflowState
 	duration time.Duration
@@ -89,6 +216,80 @@ func (t *T) DelayInState(state dwsv1alpha7.WorkflowState, duration time.Duration
 	return t
 }
 
+// StateHook is called before the workflow advances to a state, or after the workflow achieves
+// the state. Hooks can run helper pods, inject faults, or make assertions with Gomega at exact
+// points in the workflow's lifecycle.
+type StateHook func(ctx context.Context, k8sClient client.Client, t *T, workflow *dwsv1alpha7.Workflow)
+
+type TStateHook struct {
+	state  dwsv1alpha7.WorkflowState
+	before bool
+	hook   StateHook
+}
+
+// BeforeState calls the hook before the workflow advances to the state. For the Proposal state,
+// which the workflow starts in, the hook is called before waiting for the state to complete.
+// Multiple hooks can be added and are called in the order they are added.
+func (t *T) BeforeState(state dwsv1alpha7.WorkflowState, hook StateHook) *T {
+	t.options.stateHooks = append(t.options.stateHooks, TStateHook{state: state, before: true, hook: hook})
+	return t
+}
+
+// AfterState calls the hook after the workflow achieves the state (or the expected error in the
+// state), prior to any delay in the state. Multiple hooks can be added and are called in the
+// order they are added.
+func (t *T) AfterState(state dwsv1alpha7.WorkflowState, hook StateHook) *T {
+	t.options.stateHooks = append(t.options.stateHooks, TStateHook{state: state, before: false, hook: hook})
+	return t
+}
+
+// runStateHooks calls the hooks for the state
+func (t *T) runStateHooks(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, state dwsv1alpha7.WorkflowState, before bool) {
+	for _, h := range t.options.stateHooks {
+		if h.state == state && h.before == before {
+			if before {
+				By(fmt.Sprintf("Running hook before state %s", state))
+			} else {
+				By(fmt.Sprintf("Running hook after state %s", state))
+			}
+
+			h.hook(ctx, k8sClient, t, workflow)
+		}
+	}
+}
+
+// WithTimeouts overrides the suite-wide low and high timeouts for this test. The high timeout is
+// used for the provided states, or the suite's high timeout states if none are provided. A zero
+// duration leaves the corresponding suite-wide timeout in place, as does a duration shorter than
+// the suite-wide timeout; the overrides only extend the time allowed.
+func (t *T) WithTimeouts(low, high time.Duration, states ...dwsv1alpha7.WorkflowState) *T {
+	t.options.lowTimeout = low
+	t.options.highTimeout = high
+	t.options.highTimeoutStates = states
+	return t
+}
+
+// WithStateTimeout sets the timeout for a single state. It takes precedence over WithTimeouts()
+// and is used unless the suite-wide timeout for the state is longer.
+func (t *T) WithStateTimeout(state dwsv1alpha7.WorkflowState, timeout time.Duration) *T {
+	if t.options.stateTimeouts == nil {
+		t.options.stateTimeouts = make(map[dwsv1alpha7.WorkflowState]time.Duration)
+	}
+
+	t.options.stateTimeouts[state] = timeout
+	return t
+}
+
+// withTimeoutsFrom gives a test that is run on behalf of another test (e.g. the workflows that
+// create and destroy a persistent lustre instance) the same timeouts as that test.
+func (t *T) withTimeoutsFrom(parent *T) *T {
+	t.options.lowTimeout = parent.options.lowTimeout
+	t.options.highTimeout = parent.options.highTimeout
+	t.options.highTimeoutStates = parent.options.highTimeoutStates
+	t.options.stateTimeouts = parent.options.stateTimeouts
+	return t
+}
+
 // RequiresHardware marks a test as requiring real hardware (skipped in kind environments).
 func (t *T) RequiresHardware() *T {
 	t.options.hardwareRequired = true
@@ -97,6 +298,15 @@ func (t *T) RequiresHardware() *T {
 
 type TExpectError struct {
 	state dwsv1alpha7.WorkflowState
+
+	// Optional checks on the reason for the error. Regular expressions are matched against the
+	// workflow status message, and the ID, message, and error of a driver that failed in the state.
+	message       *regexp.Regexp
+	driver        *regexp.Regexp
+	driverMessage *regexp.Regexp
+	driverError   *regexp.Regexp
+	errorType     string
+	matcher       types.GomegaMatcher
 }
 
 // Expect an error at the designed state; Proceed to teardown
@@ -106,6 +316,160 @@ func (t *T) ExpectError(state dwsv1alpha7.WorkflowState) *T {
 	return t.WithLabels("error")
 }
 
+// ExpectCreateRejected expects the admission webhook to reject the creation of the workflow with
+// an error matching the regular expression, e.g. for a bad capacity, an unknown file system type,
+// duplicate directive names, or a persistentdw with no matching persistent storage. The workflow
+// is never created, so the states are not executed. Directives that cannot be parsed are
+// permitted for these tests.
+func (t *T) ExpectCreateRejected(message string) *T {
+	t.options.expectCreateRejected = mustCompile(t, "ExpectCreateRejected", message)
+	return t.WithLabels("rejected")
+}
+
+// ExpectsCreateRejected returns true if the test expects the creation of the workflow to be rejected
+func (t *T) ExpectsCreateRejected() bool {
+	return t.options.expectCreateRejected != nil
+}
+
+func (t *T) expectedError(option string) *TExpectError {
+	if t.options.expectError == nil {
+		panic(fmt.Sprintf("Test '%s' option %s requires ExpectError()", t.name, option))
+	}
+
+	return t.options.expectError
+}
+
+func mustCompile(t *T, option, expr string) *regexp.Regexp {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		panic(fmt.Sprintf("Test '%s' option %s has an invalid regular expression '%s': %v", t.name, option, expr, err))
+	}
+
+	return re
+}
+
+// WithErrorMessage expects the workflow status message of the error to match the regular expression
+func (t *T) WithErrorMessage(expr string) *T {
+	t.expectedError("WithErrorMessage").message = mustCompile(t, "WithErrorMessage", expr)
+	return t
+}
+
+// WithErrorDriver expects a driver with an ID matching the regular expression to have failed in
+// the state (e.g. "nnf")
+func (t *T) WithErrorDriver(expr string) *T {
+	t.expectedError("WithErrorDriver").driver = mustCompile(t, "WithErrorDriver", expr)
+	return t
+}
+
+// WithErrorDriverMessage expects the message of a driver that failed in the state to match the
+// regular expression
+func (t *T) WithErrorDriverMessage(expr string) *T {
+	t.expectedError("WithErrorDriverMessage").driverMessage = mustCompile(t, "WithErrorDriverMessage", expr)
+	return t
+}
+
+// WithErrorDriverError expects the error (i.e. the debug message) of a driver that failed in the
+// state to match the regular expression
+func (t *T) WithErrorDriverError(expr string) *T {
+	t.expectedError("WithErrorDriverError").driverError = mustCompile(t, "WithErrorDriverError", expr)
+	return t
+}
+
+// WithErrorType expects the type of the error of a driver that failed in the state to be the
+// errorType (i.e. "user", "internal" or "wlm"). The type prefixes the driver's error, as in
+// "user error: ...". An Error status always has a fatal severity; errors of a lesser severity are
+// reported as a TransientCondition.
+func (t *T) WithErrorType(errorType string) *T {
+	t.expectedError("WithErrorType").errorType = strings.ToLower(errorType)
+	return t
+}
+
+// WithErrorMatcher expects the workflow status of the error to satisfy the matcher, for checks
+// that cannot be expressed otherwise. For example,
+//
+//	WithErrorMatcher(HaveField("Message", ContainSubstring("timeout")))
+func (t *T) WithErrorMatcher(matcher types.GomegaMatcher) *T {
+	t.expectedError("WithErrorMatcher").matcher = matcher
+	return t
+}
+
+// failedDrivers returns the drivers that failed in the state
+func failedDrivers(status *dwsv1alpha7.WorkflowStatus, state dwsv1alpha7.WorkflowState) []dwsv1alpha7.WorkflowDriverStatus {
+	drivers := make([]dwsv1alpha7.WorkflowDriverStatus, 0)
+	for _, driver := range status.Drivers {
+		if driver.WatchState == state && driver.Status == dwsv1alpha7.StatusError {
+			drivers = append(drivers, driver)
+		}
+	}
+
+	return drivers
+}
+
+// mismatch returns a description of how the workflow status does not satisfy the expected error,
+// or an empty string if it does.
+func (e *TExpectError) mismatch(status *dwsv1alpha7.WorkflowStatus) string {
+	if e.message != nil && !e.message.MatchString(status.Message) {
+		return fmt.Sprintf("message '%s' does not match '%s'", status.Message, e.message)
+	}
+
+	if e.driver == nil && e.driverMessage == nil && e.driverError == nil && e.errorType == "" {
+		return ""
+	}
+
+	drivers := failedDrivers(status, e.state)
+	for _, driver := range drivers {
+		if e.driver != nil && !e.driver.MatchString(driver.DriverID) {
+			continue
+		}
+		if e.driverMessage != nil && !e.driverMessage.MatchString(driver.Message) {
+			continue
+		}
+		if e.driverError != nil && !e.driverError.MatchString(driver.Error) {
+			continue
+		}
+		if e.errorType != "" && !strings.HasPrefix(strings.ToLower(driver.Error), e.errorType+" error") {
+			continue
+		}
+
+		return ""
+	}
+
+	return fmt.Sprintf("no failed driver matches (driver: '%v', message: '%v', error: '%v', type: '%s'); failed drivers: %+v",
+		e.driver, e.driverMessage, e.driverError, e.errorType, drivers)
+}
+
+type TExpectTransient struct {
+	state    dwsv1alpha7.WorkflowState
+	budget   time.Duration
+	faults   []TransientFault
+	injected []TransientFault // Faults that are injected and not yet restored
+}
+
+// ExpectTransient expects the workflow to report a TransientCondition status in the state and
+// then recover to Completed within the budget. Any faults are injected prior to advancing to the
+// state and restored once the transient condition is reported. A fault that stops a controller
+// (e.g. ScaleDeploymentFault()) leaves the workflow waiting in DriverWait instead, which is
+// accepted once it persists, e.g.
+//
+//	ExpectTransient(dwsv1alpha7.StateSetup, 5*time.Minute, ScaleDeploymentFault("nnf-system", "nnf-controller-manager"))
+func (t *T) ExpectTransient(state dwsv1alpha7.WorkflowState, budget time.Duration, faults ...TransientFault) *T {
+	if state == dwsv1alpha7.StateProposal {
+		panic(fmt.Sprintf("Test '%s' cannot expect a transient condition in state %s", t.name, state))
+	}
+	if t.options.expectError != nil && t.options.expectError.state == state {
+		panic(fmt.Sprintf("Test '%s' cannot expect both an error and a transient condition in state %s", t.name, state))
+	}
+
+	t.options.expectTransient = &TExpectTransient{state: state, budget: budget, faults: faults}
+
+	// Faults affect the whole system, so don't let other tests run at the same time
+	if len(faults) != 0 {
+		t.Serialized()
+	}
+
+	return t.WithLabels("transient")
+}
+
 func (t *T) ShouldTeardown() bool {
 	if t.options.expectError != nil {
 		return true
@@ -176,6 +540,56 @@ func (t *T) WithExternalComputes() *T {
 	return t
 }
 
+func (t *T) withNodeSelection() *TNodeSelection {
+	if t.options.nodes == nil {
+		t.options.nodes = &TNodeSelection{}
+	}
+
+	return t.options.nodes
+}
+
+// WithPlacementPolicy selects the policy used to place the test's allocation sets on the Rabbits,
+// overriding the suite's policy.
+func (t *T) WithPlacementPolicy(policy PlacementPolicy) *T {
+	t.options.placementPolicy = policy
+	return t
+}
+
+// WithComputeCount limits the test to count computes. The computes are spread across the
+// Rabbits they are attached to, and storage is only allocated on those Rabbits.
+func (t *T) WithComputeCount(count int) *T {
+	if count <= 0 {
+		panic(fmt.Sprintf("Test '%s' compute count must be positive", t.name))
+	}
+
+	t.withNodeSelection().computeCount = count
+	return t
+}
+
+// WithRabbits limits storage for the test to the named Rabbits. Unless WithComputesOnRabbits()
+// is also used, the computes are those attached to the named Rabbits.
+func (t *T) WithRabbits(names ...string) *T {
+	if len(names) == 0 {
+		panic(fmt.Sprintf("Test '%s' requires at least one rabbit", t.name))
+	}
+
+	t.withNodeSelection().rabbits = names
+	return t
+}
+
+// WithComputesOnRabbits limits the computes for the test to those attached to the named Rabbits.
+// Combined with WithRabbits(), this allows computes to use storage on other Rabbits. That only
+// applies to Lustre; per-compute storage (xfs, gfs2, raw) must be on the Rabbit each compute is
+// attached to, so such a directive fails in Setup unless the Rabbits include those of the computes.
+func (t *T) WithComputesOnRabbits(names ...string) *T {
+	if len(names) == 0 {
+		panic(fmt.Sprintf("Test '%s' requires at least one rabbit", t.name))
+	}
+
+	t.withNodeSelection().computesOnRabbits = names
+	return t
+}
+
 type TContainerProfile struct {
 	name    string
 	base    string
@@ -183,10 +597,10 @@ type TContainerProfile struct {
 }
 
 type ContainerProfileOptions struct {
-	PrerunTimeoutSeconds  *int
-	PostrunTimeoutSeconds *int
-	RetryLimit            *int
-	NoStorage             bool // make any storages in the profile optional
+	PrerunTimeoutSeconds  *int `json:"prerunTimeoutSeconds,omitempty"`
+	PostrunTimeoutSeconds *int `json:"postrunTimeoutSeconds,omitempty"`
+	RetryLimit            *int `json:"retryLimit,omitempty"`
+	NoStorage             bool `json:"noStorage,omitempty"` // make any storages in the profile optional
 }
 
 func (t *T) WithContainerProfile(base string, options *ContainerProfileOptions) *T {
@@ -255,6 +669,7 @@ func (t *T) WithMgsPool(name string, count int) *T {
 
 type TGlobalLustre struct {
 	name       string
+	fsName     string // If using an existing lustre file system as the global lustre
 	mgsNids    string
 	mountRoot  string
 	namespaces map[string]lusv1alpha1.LustreFileSystemNamespaceSpec
@@ -265,8 +680,26 @@ type TGlobalLustre struct {
 	persistent *TPersistentLustre // If using a persistent lustre instance as the global lustre
 }
 
-func (t *T) WithGlobalLustre(mountRoot string, fsName string, mgsNids string) {
-	panic("reference to an existing global lustre instance is not yet supported")
+// WithGlobalLustre will create a global lustre file system that references an existing, externally
+// managed lustre file system with the given fsname and MGS NIDs, mounted at mountRoot. Namespaces
+// can be added in addition to the default `nnf-dm-system`. The file system itself is left intact
+// on cleanup; only the files created for copy_in/copy_out are removed.
+func (t *T) WithGlobalLustre(mountRoot string, fsName string, mgsNids string, namespaces ...string) *T {
+	if len(mountRoot) == 0 || len(fsName) == 0 || len(mgsNids) == 0 {
+		panic("Test option requires a mount root, fsname, and MGS NIDs for the global lustre")
+	}
+
+	t.options.globalLustre = &TGlobalLustre{
+		name:       "global-" + fsName,
+		fsName:     fsName,
+		mgsNids:    mgsNids,
+		mountRoot:  mountRoot,
+		namespaces: globalLustreNamespaces(namespaces),
+	}
+
+	t.options.globalLustre.setCopyPaths(t.directives)
+
+	return t.WithLabels("global_lustre", "global-lustre")
 }
 
 // WithGlobalLustreFromPersistentLustre will create a global lustre file system from a persistent lustre file system
@@ -276,7 +709,20 @@ func (t *T) WithGlobalLustreFromPersistentLustre(name string, namespaces []strin
 		panic("Test option requires persistent lustre")
 	}
 
-	// Convert the slice of namespaces to LustreFilsystemNamespaceSpec map and add `nnf-dm-system` by default.
+	t.options.globalLustre = &TGlobalLustre{
+		name:       "global-" + name,
+		persistent: t.options.persistentLustre,
+		mountRoot:  "/lus/" + name,
+		namespaces: globalLustreNamespaces(namespaces),
+	}
+
+	t.options.globalLustre.setCopyPaths(t.directives)
+
+	return t.WithLabels("global_lustre", "global-lustre")
+}
+
+// Convert the slice of namespaces to LustreFilsystemNamespaceSpec map and add `nnf-dm-system` by default.
+func globalLustreNamespaces(namespaces []string) map[string]lusv1alpha1.LustreFileSystemNamespaceSpec {
 	lustreNamespaces := make(map[string]lusv1alpha1.LustreFileSystemNamespaceSpec)
 	for _, ns := range append([]string{"nnf-dm-system"}, namespaces...) {
 		lustreNamespaces[ns] = lusv1alpha1.LustreFileSystemNamespaceSpec{
@@ -284,16 +730,13 @@ func (t *T) WithGlobalLustreFromPersistentLustre(name string, namespaces []strin
 		}
 	}
 
-	t.options.globalLustre = &TGlobalLustre{
-		name:       "global-" + name,
-		persistent: t.options.persistentLustre,
-		mountRoot:  "/lus/" + name,
-		namespaces: lustreNamespaces,
-	}
+	return lustreNamespaces
+}
 
-	// For copy_in/copy_out, pull the source/destination paths and add them to global lustre
+// For copy_in/copy_out, pull the source/destination paths and add them to global lustre
+func (g *TGlobalLustre) setCopyPaths(directives []string) {
 	var fsType string
-	for _, directive := range t.directives {
+	for _, directive := range directives {
 		args, _ := dwdparse.BuildArgsMap(directive)
 
 		if len(args["type"]) != 0 {
@@ -303,22 +746,20 @@ func (t *T) WithGlobalLustreFromPersistentLustre(name string, namespaces []strin
 		switch args["command"] {
 		case "copy_in":
 			if path, found := args["source"]; found {
-				t.options.globalLustre.in = path
+				g.in = path
 			}
 		case "copy_out":
 			if path, found := args["destination"]; found {
 				// Account for index mount directories in the path. This only works for file-file
 				// data movement to assume where the '*' goes for the index mount directories.
 				if fsType == "gfs2" || fsType == "xfs" {
-					t.options.globalLustre.out = filepath.Join(filepath.Dir(path), "*", filepath.Base(path))
+					g.out = filepath.Join(filepath.Dir(path), "*", filepath.Base(path))
 				} else {
-					t.options.globalLustre.out = path
+					g.out = path
 				}
 			}
 		}
 	}
-
-	return t.WithLabels("global_lustre", "global-lustre")
 }
 
 type TDuplicate struct {
@@ -384,6 +825,7 @@ func (t *T) Prepare(ctx context.Context, k8sClient client.Client) error {
 		}
 
 		Expect(k8sClient.Create(ctx, profile)).To(Succeed())
+		t.resources.StorageProfiles++
 	}
 
 	if o.containerProfile != nil {
@@ -428,6 +870,7 @@ func (t *T) Prepare(ctx context.Context, k8sClient client.Client) error {
 		}
 
 		Expect(k8sClient.Create(ctx, profile)).To(Succeed())
+		t.resources.ContainerProfiles++
 	}
 
 	if o.cleanupPersistent != nil {
@@ -439,12 +882,14 @@ func (t *T) Prepare(ctx context.Context, k8sClient client.Client) error {
 		name := o.persistentLustre.name
 		capacity := o.persistentLustre.capacity
 
-		o.persistentLustre.create = MakeTest(name+"-create",
-			fmt.Sprintf("#DW create_persistent type=lustre name=%s capacity=%s", name, capacity)).
-			WithPermissions(t.workflow.Spec.UserID, t.workflow.Spec.GroupID)
-		o.persistentLustre.destroy = MakeTest(name+"-destroy",
-			fmt.Sprintf("#DW destroy_persistent name=%s", name)).
-			WithPermissions(t.workflow.Spec.UserID, t.workflow.Spec.GroupID)
+		create := CreatePersistent("lustre", name, capacity)
+
+		o.persistentLustre.create = MakeTestFromDirectives(name+"-create", create).
+			WithPermissions(t.workflow.Spec.UserID, t.workflow.Spec.GroupID).
+			withTimeoutsFrom(t)
+		o.persistentLustre.destroy = MakeTestFromDirectives(name+"-destroy", create.Destroy()).
+			WithPermissions(t.workflow.Spec.UserID, t.workflow.Spec.GroupID).
+			withTimeoutsFrom(t)
 
 		// Create the persistent lustre instance
 		By(fmt.Sprintf("Creating persistent lustre instance '%s'", name))
@@ -465,6 +910,7 @@ func (t *T) Prepare(ctx context.Context, k8sClient client.Client) error {
 		if ht, ok := ctx.Value("highTimeout").(time.Duration); ok {
 			storageReadyTimeout = ht
 		}
+		storageReadyTimeout = max(storageReadyTimeout, o.highTimeout)
 		Eventually(func(g Gomega) bool {
 			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(storage), storage)).To(Succeed())
 			return storage.Status.Ready
@@ -500,12 +946,14 @@ func (t *T) Prepare(ctx context.Context, k8sClient client.Client) error {
 			profile.Data.LustreStorage.MgtOptions.ExternalMGS = o.storageProfile.externalMgs
 			profile.Data.LustreStorage.MgtOptions.StandaloneMGTPoolName = ""
 			Expect(k8sClient.Create(ctx, profile)).To(Succeed())
+			t.resources.StorageProfiles++
 		}
 	}
 
 	if o.mgsPool != nil {
 		for i := 0; i < o.mgsPool.count; i++ {
-			mgsPersistentStorage := MakeTest(fmt.Sprintf("MGS Pool %s-%d-create", o.mgsPool.name, i), fmt.Sprintf("#DW create_persistent type=lustre name=%s-%d profile=%s", o.mgsPool.name, i, o.mgsPool.name)).WithStorageProfileStandaloneMGT(o.mgsPool.name)
+			mgs := CreatePersistent("lustre", fmt.Sprintf("%s-%d", o.mgsPool.name, i), "").WithProfile(o.mgsPool.name)
+			mgsPersistentStorage := MakeTestFromDirectives(fmt.Sprintf("MGS Pool %s-create", mgs.Name()), mgs).WithStorageProfileStandaloneMGT(o.mgsPool.name).withTimeoutsFrom(t)
 
 			By(fmt.Sprintf("Creating persistent lustre MGS '%s'", o.mgsPool.name))
 			Expect(k8sClient.Create(ctx, mgsPersistentStorage.Workflow())).To(Succeed())
@@ -536,7 +984,7 @@ func (t *T) Prepare(ctx context.Context, k8sClient client.Client) error {
 			lustre.Spec.Name = o.globalLustre.persistent.fsName
 			lustre.Spec.MgsNids = o.globalLustre.persistent.mgsNids
 		} else {
-			panic("reference to an existing global lustre file system is not yet implemented")
+			lustre.Spec.Name = o.globalLustre.fsName
 		}
 
 		By(fmt.Sprintf("Creating a global lustre file system '%s' @ '%s'", client.ObjectKeyFromObject(lustre), lustre.Spec.MountRoot))
@@ -550,6 +998,10 @@ func (t *T) Prepare(ctx context.Context, k8sClient client.Client) error {
 		}
 	}
 
+	if o.scenario != nil {
+		return o.scenario.prepare(ctx, k8sClient)
+	}
+
 	return nil
 }
 
@@ -558,17 +1010,27 @@ func (t *T) Prepare(ctx context.Context, k8sClient client.Client) error {
 // between options are correct.
 func (t *T) Cleanup(ctx context.Context, k8sClient client.Client) error {
 	o := t.options
+	defer t.reportResources()
+
+	if o.scenario != nil {
+		if err := o.scenario.cleanup(ctx, k8sClient); err != nil {
+			return err
+		}
+	}
+
+	// A global lustre created from a persistent lustre instance is torn down along with the
+	// test files, but an existing lustre file system is not; remove the copy_in/copy_out files.
+	// This is done prior to deleting the global lustre, which the helper pod mounts.
+	if o.globalLustre != nil && o.globalLustre.persistent == nil &&
+		(len(o.globalLustre.in) > 0 || len(o.globalLustre.out) > 0) {
+		CleanupCopyInCopyOut(ctx, k8sClient, t, t.options)
+	}
 
 	// Remove any helper pods that may have been used (e.g. copy_in, copy_out)
 	if len(t.helperPods) > 0 {
 		CleanupHelperPods(ctx, k8sClient, t)
 	}
 
-	// TODO: If a real lustre filesystem is used rather than persistent, we
-	// should fire up another helper pod to delete copy_in/copy_out files (i.e.)
-	// to.globalLustre.in/out. In the meantime, it is assumed the global lustre
-	// is torn down.
-
 	if o.globalLustre != nil {
 		By(fmt.Sprintf("Deleting global lustre '%s'", o.globalLustre.name))
 		lustre := &lusv1alpha1.LustreFileSystem{
@@ -584,7 +1046,8 @@ func (t *T) Cleanup(ctx context.Context, k8sClient client.Client) error {
 
 	if o.mgsPool != nil {
 		for i := 0; i < o.mgsPool.count; i++ {
-			mgsPersistentStorage := MakeTest(fmt.Sprintf("MGS Pool %s-%d-destroy", o.mgsPool.name, i), fmt.Sprintf("#DW destroy_persistent name=%s-%d", o.mgsPool.name, i))
+			mgs := DestroyPersistent(fmt.Sprintf("%s-%d", o.mgsPool.name, i))
+			mgsPersistentStorage := MakeTestFromDirectives(fmt.Sprintf("MGS Pool %s-destroy", mgs.Name()), mgs).withTimeoutsFrom(t)
 
 			By(fmt.Sprintf("Destroying persistent lustre MGS '%s'", o.mgsPool.name))
 			Expect(k8sClient.Create(ctx, mgsPersistentStorage.Workflow())).To(Succeed())
@@ -599,8 +1062,9 @@ func (t *T) Cleanup(ctx context.Context, k8sClient client.Client) error {
 		name := o.cleanupPersistent.name
 		By(fmt.Sprintf("Destroying persistent filesystem '%s'", name))
 
-		test := MakeTest(name+"-destroy", fmt.Sprintf("#DW destroy_persistent name=%s", name)).
-			WithPermissions(t.workflow.Spec.UserID, t.workflow.Spec.GroupID)
+		test := MakeTestFromDirectives(name+"-destroy", DestroyPersistent(name)).
+			WithPermissions(t.workflow.Spec.UserID, t.workflow.Spec.GroupID).
+			withTimeoutsFrom(t)
 		Expect(k8sClient.Create(ctx, test.Workflow())).To(Succeed())
 		test.Execute(ctx, k8sClient)
 		Expect(k8sClient.Delete(ctx, test.Workflow())).To(Succeed())
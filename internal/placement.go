@@ -0,0 +1,287 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+// PlacementPolicy orders the Rabbits an allocation set may be placed on. The allocation
+// strategy then takes as many Rabbits from the front of the list as it requires, after the
+// Lustre MGT constraints are applied. Allocation sets using the AllocatePerCompute strategy are
+// always placed on the Rabbits attached to the computes and do not consult the policy.
+//
+// A policy is selected for a test with WithPlacementPolicy(), or for the suite with the
+// `-placement` flag. See ParsePlacementPolicy() for the policy names.
+type PlacementPolicy interface {
+	// Order returns the Rabbits in order of preference. Rabbits that must not be used are
+	// omitted.
+	Order(ctx context.Context, k8sClient client.Client, request PlacementRequest) ([]string, error)
+
+	// String returns the policy name, as accepted by ParsePlacementPolicy()
+	String() string
+}
+
+// PlacementRequest describes the allocation set being placed
+type PlacementRequest struct {
+	Workflow      string
+	AllocationSet *dwsv1alpha7.StorageAllocationSet
+
+	// Rabbits selected for the test in system configuration order
+	Rabbits []string
+}
+
+// PlacementPolicyContextKey is the context key for the suite's placement policy
+const PlacementPolicyContextKey = "placementPolicy"
+
+// defaultPlacementPolicy is used when neither the test nor the suite select a policy
+var defaultPlacementPolicy PlacementPolicy = &workflowHashPlacementPolicy{}
+
+// ParsePlacementPolicy returns the placement policy for the value. Supported values are
+//
+//	default                              Rotate the Rabbits by a hash of the workflow name
+//	round-robin                          Rotate the Rabbits by one for each allocation set placed
+//	random[:SEED]                        Shuffle the Rabbits; the seed defaults to the current time
+//	capacity                             Prefer Rabbits with the most free capacity
+//	explicit:LABEL=RABBIT,...[;LABEL=..] Use the listed Rabbits for each allocation set label
+//
+// An empty value returns nil, which selects the default.
+func ParsePlacementPolicy(value string) (PlacementPolicy, error) {
+	name, arg, _ := strings.Cut(value, ":")
+
+	switch name {
+	case "":
+		return nil, nil
+	case "default":
+		return defaultPlacementPolicy, nil
+	case "round-robin":
+		return RoundRobinPlacementPolicy(), nil
+	case "random":
+		seed := time.Now().UnixNano()
+		if arg != "" {
+			var err error
+			if seed, err = strconv.ParseInt(arg, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid random placement seed '%s': %w", arg, err)
+			}
+		}
+		return RandomPlacementPolicy(seed), nil
+	case "capacity":
+		return CapacityPlacementPolicy(), nil
+	case "explicit":
+		placements := make(map[string][]string)
+		for _, placement := range strings.Split(arg, ";") {
+			label, rabbits, found := strings.Cut(placement, "=")
+			if !found || label == "" || rabbits == "" {
+				return nil, fmt.Errorf("invalid explicit placement '%s', expected LABEL=RABBIT,...", placement)
+			}
+			placements[label] = strings.Split(rabbits, ",")
+		}
+		return ExplicitPlacementPolicy(placements), nil
+	}
+
+	return nil, fmt.Errorf("unknown placement policy '%s'", value)
+}
+
+// getPlacementPolicy returns the test's placement policy, falling back to the suite's policy
+func (t *T) getPlacementPolicy(ctx context.Context) PlacementPolicy {
+	if t.options.placementPolicy != nil {
+		return t.options.placementPolicy
+	}
+
+	if policy, ok := ctx.Value(PlacementPolicyContextKey).(PlacementPolicy); ok && policy != nil {
+		return policy
+	}
+
+	return defaultPlacementPolicy
+}
+
+// rotate returns a copy of the rabbits rotated left by offset
+func rotate(rabbits []string, offset int) []string {
+	if len(rabbits) == 0 {
+		return []string{}
+	}
+
+	offset %= len(rabbits)
+	return append(slices.Clone(rabbits[offset:]), rabbits[:offset]...)
+}
+
+// workflowHashPlacementPolicy rotates the Rabbits by a hash of the workflow name so that
+// concurrently running tests do not all start on the first Rabbit, while the placement of any
+// one test is deterministic.
+type workflowHashPlacementPolicy struct{}
+
+func (*workflowHashPlacementPolicy) Order(ctx context.Context, k8sClient client.Client, request PlacementRequest) ([]string, error) {
+	hash := fnv.New32a()
+	hash.Write([]byte(request.Workflow))
+
+	return rotate(request.Rabbits, int(hash.Sum32()%uint32(max(len(request.Rabbits), 1)))), nil
+}
+
+func (*workflowHashPlacementPolicy) String() string { return "default" }
+
+type roundRobinPlacementPolicy struct {
+	lock sync.Mutex
+	next int
+}
+
+// RoundRobinPlacementPolicy rotates the Rabbits by one for each allocation set placed
+func RoundRobinPlacementPolicy() PlacementPolicy {
+	return &roundRobinPlacementPolicy{}
+}
+
+func (p *roundRobinPlacementPolicy) Order(ctx context.Context, k8sClient client.Client, request PlacementRequest) ([]string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	rabbits := rotate(request.Rabbits, p.next)
+	p.next++
+
+	return rabbits, nil
+}
+
+func (*roundRobinPlacementPolicy) String() string { return "round-robin" }
+
+type randomPlacementPolicy struct {
+	lock sync.Mutex
+	seed int64
+	rand *rand.Rand
+}
+
+// RandomPlacementPolicy shuffles the Rabbits using a random source with the given seed, so an
+// uneven placement that exposes a problem can be reproduced by running with the same seed.
+func RandomPlacementPolicy(seed int64) PlacementPolicy {
+	return &randomPlacementPolicy{seed: seed, rand: rand.New(rand.NewSource(seed))}
+}
+
+func (p *randomPlacementPolicy) Order(ctx context.Context, k8sClient client.Client, request PlacementRequest) ([]string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	rabbits := slices.Clone(request.Rabbits)
+	p.rand.Shuffle(len(rabbits), func(i, j int) { rabbits[i], rabbits[j] = rabbits[j], rabbits[i] })
+
+	return rabbits, nil
+}
+
+func (p *randomPlacementPolicy) String() string { return fmt.Sprintf("random:%d", p.seed) }
+
+type capacityPlacementPolicy struct{}
+
+// CapacityPlacementPolicy orders the Rabbits by their free capacity, most first. The free
+// capacity is the capacity reported by the Rabbit's DWS Storage resource less the allocations
+// assigned to it by every Servers resource. Rabbits whose Storage is not ready, or that do not
+// have the minimum capacity of the allocation set free, are omitted.
+func CapacityPlacementPolicy() PlacementPolicy {
+	return &capacityPlacementPolicy{}
+}
+
+func (*capacityPlacementPolicy) Order(ctx context.Context, k8sClient client.Client, request PlacementRequest) ([]string, error) {
+	storages := &dwsv1alpha7.StorageList{}
+	if err := k8sClient.List(ctx, storages); err != nil {
+		return nil, err
+	}
+
+	serversList := &dwsv1alpha7.ServersList{}
+	if err := k8sClient.List(ctx, serversList); err != nil {
+		return nil, err
+	}
+
+	free := make(map[string]int64)
+	for _, storage := range storages.Items {
+		if storage.Status.Status == dwsv1alpha7.ReadyStatus {
+			free[storage.Name] = storage.Status.Capacity
+		}
+	}
+
+	for _, servers := range serversList.Items {
+		for _, allocationSet := range servers.Spec.AllocationSets {
+			for _, storage := range allocationSet.Storage {
+				free[storage.Name] -= allocationSet.AllocationSize * int64(storage.AllocationCount)
+			}
+		}
+	}
+
+	rabbits := make([]string, 0, len(request.Rabbits))
+	for _, rabbit := range request.Rabbits {
+		if capacity, found := free[rabbit]; found && capacity >= request.AllocationSet.MinimumCapacity {
+			rabbits = append(rabbits, rabbit)
+		}
+	}
+
+	sort.SliceStable(rabbits, func(i, j int) bool { return free[rabbits[i]] > free[rabbits[j]] })
+
+	return rabbits, nil
+}
+
+func (*capacityPlacementPolicy) String() string { return "capacity" }
+
+type explicitPlacementPolicy struct {
+	placements map[string][]string
+}
+
+// ExplicitPlacementPolicy places each allocation set on the Rabbits listed for its label (e.g.
+// "mgt", "mdt", "ost", "xfs"), reproducing an exact layout. Allocation sets with a label that
+// is not listed use the default policy.
+func ExplicitPlacementPolicy(placements map[string][]string) PlacementPolicy {
+	return &explicitPlacementPolicy{placements: placements}
+}
+
+func (p *explicitPlacementPolicy) Order(ctx context.Context, k8sClient client.Client, request PlacementRequest) ([]string, error) {
+	rabbits, found := p.placements[request.AllocationSet.Label]
+	if !found {
+		return defaultPlacementPolicy.Order(ctx, k8sClient, request)
+	}
+
+	for _, rabbit := range rabbits {
+		if !slices.Contains(request.Rabbits, rabbit) {
+			return nil, fmt.Errorf("explicit placement rabbit '%s' is not one of the selected rabbits %v", rabbit, request.Rabbits)
+		}
+	}
+
+	return slices.Clone(rabbits), nil
+}
+
+func (p *explicitPlacementPolicy) String() string {
+	labels := make([]string, 0, len(p.placements))
+	for label := range p.placements {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	placements := make([]string, len(labels))
+	for index, label := range labels {
+		placements[index] = label + "=" + strings.Join(p.placements[label], ",")
+	}
+
+	return "explicit:" + strings.Join(placements, ";")
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+func TestPlacementPolicies(t *testing.T) {
+	rabbits := []string{"rabbit-0", "rabbit-1", "rabbit-2"}
+	request := func(label string) PlacementRequest {
+		return PlacementRequest{Workflow: "test", AllocationSet: &dwsv1alpha7.StorageAllocationSet{Label: label}, Rabbits: rabbits}
+	}
+
+	roundRobin, _ := ParsePlacementPolicy("round-robin")
+	first, _ := roundRobin.Order(context.TODO(), nil, request("ost"))
+	second, _ := roundRobin.Order(context.TODO(), nil, request("ost"))
+	if !reflect.DeepEqual(first, rabbits) || !reflect.DeepEqual(second, []string{"rabbit-1", "rabbit-2", "rabbit-0"}) {
+		t.Errorf("unexpected round-robin order %v, %v", first, second)
+	}
+
+	// The same seed produces the same placements
+	a, _ := ParsePlacementPolicy("random:42")
+	b, _ := ParsePlacementPolicy("random:42")
+	for i := 0; i < 5; i++ {
+		orderA, _ := a.Order(context.TODO(), nil, request("ost"))
+		orderB, _ := b.Order(context.TODO(), nil, request("ost"))
+		if !reflect.DeepEqual(orderA, orderB) {
+			t.Errorf("random placement is not reproducible: %v vs %v", orderA, orderB)
+		}
+	}
+	if a.String() != "random:42" {
+		t.Errorf("unexpected policy name '%s'", a)
+	}
+
+	explicit, err := ParsePlacementPolicy("explicit:mgt=rabbit-2;ost=rabbit-0,rabbit-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order, _ := explicit.Order(context.TODO(), nil, request("mgt")); !reflect.DeepEqual(order, []string{"rabbit-2"}) {
+		t.Errorf("unexpected explicit mgt placement %v", order)
+	}
+	if order, _ := explicit.Order(context.TODO(), nil, request("mdt")); len(order) != 3 {
+		t.Errorf("expected the default placement for an unlisted label: %v", order)
+	}
+	if explicit.String() != "explicit:mgt=rabbit-2;ost=rabbit-0,rabbit-1" {
+		t.Errorf("unexpected policy name '%s'", explicit)
+	}
+
+	if _, err := ExplicitPlacementPolicy(map[string][]string{"ost": {"rabbit-9"}}).Order(context.TODO(), nil, request("ost")); err == nil {
+		t.Errorf("expected an error for an unselected rabbit")
+	}
+
+	for _, value := range []string{"unknown", "random:seed", "explicit:mgt"} {
+		if _, err := ParsePlacementPolicy(value); err == nil {
+			t.Errorf("expected an error parsing '%s'", value)
+		}
+	}
+}
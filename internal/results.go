@@ -0,0 +1,285 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/ginkgo/v2/types"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+// StateTiming is the timing of a workflow state transition. Duration is measured from the start
+// of the state's handler to its completion, and includes any work done by the test (e.g. assigning
+// servers). Times are taken from the workflow status where DWS reports them.
+type StateTiming struct {
+	State dwsv1alpha7.WorkflowState `json:"state"`
+
+	// Total time taken by the state's handler
+	Duration time.Duration `json:"duration"`
+
+	// Time taken for the desired state update to be accepted
+	Update time.Duration `json:"update"`
+
+	// Time the desired state changed and the time the workflow became ready in the state, and
+	// the time between the two
+	DesiredStateChange time.Time     `json:"desiredStateChange"`
+	ReadyChange        time.Time     `json:"readyChange"`
+	Elapsed            time.Duration `json:"elapsed"`
+
+	// Completion times of the drivers watching the state
+	Drivers []DriverTiming `json:"drivers,omitempty"`
+}
+
+// DriverTiming is the completion time of a driver, and the time it took from the desired state
+// change.
+type DriverTiming struct {
+	DriverID string        `json:"driverID"`
+	DWDIndex int           `json:"dwdIndex"`
+	Complete time.Time     `json:"complete"`
+	Elapsed  time.Duration `json:"elapsed"`
+}
+
+// StateTimings are the timings of the states executed by a test, in order
+type StateTimings []StateTiming
+
+// ResultsEnvVar selects the file the results of a run are written to when the `-results` flag
+// is not provided.
+const ResultsEnvVar = "NNF_TEST_RESULTS"
+
+// StateTimingsReportEntry is the name of the report entry holding a test's state timings
+const StateTimingsReportEntry = "State Timings"
+
+func (timings StateTimings) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%-10s %10s %10s %10s  %s\n", "State", "Duration", "Update", "Elapsed", "Drivers")
+	for _, timing := range timings {
+		drivers := make([]string, len(timing.Drivers))
+		for index, driver := range timing.Drivers {
+			drivers[index] = fmt.Sprintf("%s[%d]=%s", driver.DriverID, driver.DWDIndex, driver.Elapsed.Round(time.Millisecond))
+		}
+
+		fmt.Fprintf(b, "%-10s %10s %10s %10s  %s\n", timing.State,
+			timing.Duration.Round(time.Millisecond),
+			timing.Update.Round(time.Millisecond),
+			timing.Elapsed.Round(time.Millisecond),
+			strings.Join(drivers, " "))
+	}
+
+	return b.String()
+}
+
+// StateTimings returns the timings of each state the test has executed, in order
+func (t *T) StateTimings() StateTimings {
+	return slices.Clone(t.timings)
+}
+
+// recordTransition fills in the details of the state transition once the workflow has achieved
+// the state. The requested time is when the desired state update was accepted. Transitions made
+// outside of Execute() (e.g. the teardown during cleanup) are not recorded.
+func (t *T) recordTransition(workflow *dwsv1alpha7.Workflow, state dwsv1alpha7.WorkflowState, requested time.Time, update time.Duration) {
+	if len(t.timings) == 0 {
+		return
+	}
+
+	timing := &t.timings[len(t.timings)-1]
+	if timing.State != state || !timing.DesiredStateChange.IsZero() {
+		return
+	}
+
+	status := &workflow.Status
+
+	timing.Update = update
+	timing.DesiredStateChange = requested
+	if status.DesiredStateChange != nil {
+		timing.DesiredStateChange = status.DesiredStateChange.Time
+	}
+
+	if status.Ready && status.State == state {
+		timing.ReadyChange = time.Now()
+		if status.ReadyChange != nil {
+			timing.ReadyChange = status.ReadyChange.Time
+		}
+		timing.Elapsed = timing.ReadyChange.Sub(timing.DesiredStateChange)
+	}
+
+	for _, driver := range status.Drivers {
+		if driver.WatchState != state || driver.CompleteTime == nil {
+			continue
+		}
+
+		timing.Drivers = append(timing.Drivers, DriverTiming{
+			DriverID: driver.DriverID,
+			DWDIndex: driver.DWDIndex,
+			Complete: driver.CompleteTime.Time,
+			Elapsed:  driver.CompleteTime.Time.Sub(timing.DesiredStateChange),
+		})
+	}
+}
+
+// reportTimings attaches the state timings to the spec report
+func (t *T) reportTimings() {
+	if len(t.timings) != 0 {
+		AddReportEntry(StateTimingsReportEntry, t.StateTimings(), ReportEntryVisibilityFailureOrVerbose)
+	}
+}
+
+// TestResult is the result of a single spec
+type TestResult struct {
+	Name     string        `json:"name"`
+	Labels   []string      `json:"labels,omitempty"`
+	State    string        `json:"state"`
+	Failure  string        `json:"failure,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Timings  StateTimings  `json:"timings,omitempty"`
+
+	// Resources created by the test
+	Resources ResourceCounts `json:"resources"`
+}
+
+// Passed returns true if the spec passed
+func (r *TestResult) Passed() bool {
+	return r.State == types.SpecStatePassed.String()
+}
+
+// Results are the results of every spec in a run. Durations are in nanoseconds.
+type Results struct {
+	Suite    string       `json:"suite"`
+	Started  time.Time    `json:"started"`
+	Finished time.Time    `json:"finished"`
+	Tests    []TestResult `json:"tests"`
+}
+
+// BuildResults returns the results of a run from the suite report. The report includes the
+// specs run by every parallel process, so this is called from a ReportAfterSuite node.
+func BuildResults(report Report) (*Results, error) {
+	results := &Results{
+		Suite:    report.SuiteDescription,
+		Started:  report.StartTime,
+		Finished: report.EndTime,
+		Tests:    make([]TestResult, 0),
+	}
+
+	for _, spec := range report.SpecReports {
+		if spec.LeafNodeType != types.NodeTypeIt {
+			continue
+		}
+
+		// The outermost container is the suite's Describe()
+		name := spec.FullText()
+		if len(spec.ContainerHierarchyTexts) > 1 {
+			name = strings.Join(spec.ContainerHierarchyTexts[1:], " ")
+		}
+
+		result := TestResult{
+			Name:     name,
+			Labels:   spec.Labels(),
+			State:    spec.State.String(),
+			Duration: spec.RunTime,
+		}
+		if spec.State.Is(types.SpecStateFailureStates) {
+			result.Failure = spec.Failure.Message
+		}
+
+		timings, err := reportedTimings(spec)
+		if err != nil {
+			return nil, fmt.Errorf("test '%s': %w", result.Name, err)
+		}
+		result.Timings = timings
+
+		resources, err := reportedResources(spec)
+		if err != nil {
+			return nil, fmt.Errorf("test '%s': %w", result.Name, err)
+		}
+		result.Resources = resources
+
+		results.Tests = append(results.Tests, result)
+	}
+
+	return results, nil
+}
+
+// reportedTimings returns the state timings attached to the spec report, in order. A soak attaches
+// the timings of each iteration, and a scenario those of each workflow, so these are the timings
+// of every iteration or workflow. The raw value is
+// only available on the process that ran the spec; otherwise it is decoded from its JSON.
+func reportedTimings(spec SpecReport) (StateTimings, error) {
+	var all StateTimings
+	for _, entry := range spec.ReportEntries {
+		if entry.Name != StateTimingsReportEntry {
+			continue
+		}
+
+		timings, ok := entry.Value.GetRawValue().(StateTimings)
+		if !ok {
+			if err := json.Unmarshal([]byte(entry.Value.AsJSON), &timings); err != nil {
+				return nil, err
+			}
+		}
+
+		all = append(all, timings...)
+	}
+
+	return all, nil
+}
+
+// reportedResources returns the sum of the resource counts attached to the spec report. A soak
+// attaches the counts of each iteration.
+func reportedResources(spec SpecReport) (ResourceCounts, error) {
+	resources := ResourceCounts{}
+	for _, entry := range spec.ReportEntries {
+		if entry.Name != ResourcesReportEntry {
+			continue
+		}
+
+		counts, ok := entry.Value.GetRawValue().(ResourceCounts)
+		if !ok {
+			if err := json.Unmarshal([]byte(entry.Value.AsJSON), &counts); err != nil {
+				return resources, err
+			}
+		}
+
+		resources = resources.add(counts)
+	}
+
+	return resources, nil
+}
+
+// WriteResults writes the results as JSON to the file
+func WriteResults(file string, results *Results) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, data, 0644)
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/v2/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+func TestRecordTransition(t *testing.T) {
+	test := MakeTest("Timing", "#DW jobdw type=xfs name=timing capacity=50GB")
+
+	requested := time.Now()
+	desired := metav1.NewMicroTime(requested.Add(-time.Second))
+	ready := metav1.NewMicroTime(requested.Add(40 * time.Second))
+	complete := metav1.NewMicroTime(requested.Add(30 * time.Second))
+
+	workflow := test.Workflow()
+	workflow.Status = dwsv1alpha7.WorkflowStatus{
+		State:              dwsv1alpha7.StateSetup,
+		Ready:              true,
+		DesiredStateChange: &desired,
+		ReadyChange:        &ready,
+		Drivers: []dwsv1alpha7.WorkflowDriverStatus{
+			{DriverID: "nnf", WatchState: dwsv1alpha7.StateSetup, CompleteTime: &complete},
+			{DriverID: "nnf", WatchState: dwsv1alpha7.StateTeardown},
+		},
+	}
+
+	test.timeState(dwsv1alpha7.StateSetup, func() {
+		test.recordTransition(workflow, dwsv1alpha7.StateSetup, requested, time.Second)
+	})
+
+	// Transitions outside of a timed state are not recorded
+	test.recordTransition(workflow, dwsv1alpha7.StateTeardown, requested, time.Second)
+
+	timings := test.StateTimings()
+	if len(timings) != 1 {
+		t.Fatalf("expected 1 timing, got %d", len(timings))
+	}
+
+	timing := timings[0]
+	if timing.Update != time.Second || timing.Elapsed != 41*time.Second || !timing.DesiredStateChange.Equal(desired.Time) {
+		t.Errorf("unexpected timing %+v", timing)
+	}
+	if len(timing.Drivers) != 1 || timing.Drivers[0].Elapsed != 31*time.Second {
+		t.Errorf("unexpected driver timings %+v", timing.Drivers)
+	}
+}
+
+func TestLockstepTimings(t *testing.T) {
+	first := MakeTest("First", "#DW jobdw type=xfs name=first capacity=50GB")
+	second := MakeTest("Second", "#DW jobdw type=xfs name=second capacity=50GB")
+
+	// A scenario starts timing every workflow before waiting for any of them
+	firstDone := first.startTiming(dwsv1alpha7.StateSetup)
+	secondDone := second.startTiming(dwsv1alpha7.StateSetup)
+	time.Sleep(10 * time.Millisecond)
+	firstDone()
+	secondDone()
+
+	for _, test := range []*T{first, second} {
+		timings := test.StateTimings()
+		if len(timings) != 1 || timings[0].State != dwsv1alpha7.StateSetup || timings[0].Duration < 10*time.Millisecond {
+			t.Errorf("unexpected timings for '%s': %+v", test.Name(), timings)
+		}
+	}
+}
+
+func TestBuildResults(t *testing.T) {
+	timings := StateTimings{{State: dwsv1alpha7.StateSetup, Duration: 40 * time.Second}}
+
+	// Specs run by another parallel process only have the JSON of the report entry value
+	data, err := json.Marshal(types.WrapEntryValue(timings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := types.ReportEntryValue{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	report := types.Report{
+		SuiteDescription: "Integration Test Suite",
+		SpecReports: types.SpecReports{
+			{
+				ContainerHierarchyTexts: []string{"NNF Integration Test", "XFS"},
+				LeafNodeType:            types.NodeTypeIt,
+				LeafNodeText:            "Executes",
+				State:                   types.SpecStatePassed,
+				ReportEntries:           types.ReportEntries{{Name: StateTimingsReportEntry, Value: types.WrapEntryValue(timings)}},
+			},
+			{
+				ContainerHierarchyTexts: []string{"NNF Integration Test", "GFS2"},
+				LeafNodeType:            types.NodeTypeIt,
+				LeafNodeText:            "Executes",
+				State:                   types.SpecStateFailed,
+				Failure:                 types.Failure{Message: "timed out"},
+				ReportEntries:           types.ReportEntries{{Name: StateTimingsReportEntry, Value: decoded}},
+			},
+			{
+				ContainerHierarchyTexts: []string{"NNF Integration Test", "XFS Soak"},
+				LeafNodeType:            types.NodeTypeIt,
+				LeafNodeText:            "Soaks",
+				State:                   types.SpecStatePassed,
+				ReportEntries: types.ReportEntries{
+					{Name: StateTimingsReportEntry, Value: types.WrapEntryValue(timings)},
+					{Name: StateTimingsReportEntry, Value: decoded},
+				},
+			},
+			{LeafNodeType: types.NodeTypeBeforeSuite, State: types.SpecStatePassed},
+		},
+	}
+
+	results, err := BuildResults(report)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results.Tests) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results.Tests))
+	}
+
+	xfs, gfs2, soak := results.Tests[0], results.Tests[1], results.Tests[2]
+	if xfs.Name != "XFS" || !xfs.Passed() || len(xfs.Timings) != 1 {
+		t.Errorf("unexpected result %+v", xfs)
+	}
+	if gfs2.Passed() || gfs2.Failure != "timed out" || len(gfs2.Timings) != 1 || gfs2.Timings[0].Duration != 40*time.Second {
+		t.Errorf("unexpected result %+v", gfs2)
+	}
+
+	// Every iteration of a soak is included
+	if len(soak.Timings) != 2 {
+		t.Errorf("expected the timings of both soak iterations: %+v", soak)
+	}
+}
@@ -0,0 +1,393 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+// TScenario runs several workflows concurrently within a single spec. The workflows are advanced
+// in lockstep; every workflow is advanced to a state before waiting for any of them to achieve it,
+// and every workflow achieves a state before any workflow advances to the next state. With
+// Staggered(), each workflow is advanced a delay after the previous one.
+type TScenario struct {
+	tests   []*T
+	stagger time.Duration
+
+	// The state the workflows contend for a limited resource, if any
+	contention *TContention
+}
+
+// TContention is the expected outcome of the workflows contending for a limited resource (e.g.
+// capacity, MGS pool slots, or a persistent storage name) in a state.
+type TContention struct {
+	state   dwsv1alpha7.WorkflowState
+	winners int
+	message *regexp.Regexp
+}
+
+// contentionLossPersistence is how long a TransientCondition must persist in the contention state
+// before the workflow is considered to have lost. Brief transient conditions, such as a retry
+// after a conflict, are not losses.
+const contentionLossPersistence = 30 * time.Second
+
+// MakeScenario creates a test that runs the tests concurrently. The scenario's own options (e.g.
+// WithMgsPool()) are prepared before, and cleaned up after, those of the tests. The tests always
+// execute the default states, so options that change the states or their expected outcome (e.g.
+// WithStates(), StopAfter(), ExpectError()) are rejected; use ExpectWinners(). For example, two
+// workflows that each need most of the capacity of the same Rabbit, where only one may win
+//
+//	MakeScenario("Capacity Contention",
+//		MakeTest("Large 1", "#DW jobdw type=xfs name=large-1 capacity=3TB").WithRabbits("rabbit-node-1"),
+//		MakeTest("Large 2", "#DW jobdw type=xfs name=large-2 capacity=3TB").WithRabbits("rabbit-node-1"),
+//	).Serialized().ExpectWinners(dwsv1alpha7.StateSetup, 1)
+func MakeScenario(name string, tests ...*T) *T {
+	if len(tests) < 2 {
+		panic(fmt.Sprintf("Scenario '%s' requires at least two tests", name))
+	}
+
+	t := MakeTest(name).WithLabels("scenario")
+
+	names := make([]string, 0, len(tests))
+	for _, test := range tests {
+		if slices.Contains(names, test.WorkflowName()) {
+			panic(fmt.Sprintf("Scenario '%s' has more than one workflow named '%s'", name, test.WorkflowName()))
+		}
+		names = append(names, test.WorkflowName())
+
+		if option := test.unsupportedInScenario(); option != "" {
+			panic(fmt.Sprintf("Scenario '%s' test '%s' option %s is not supported in a scenario", name, test.Name(), option))
+		}
+
+		for _, label := range test.labels {
+			if !slices.Contains(t.labels, label) {
+				t.labels = append(t.labels, label)
+			}
+		}
+	}
+
+	t.options.scenario = &TScenario{tests: tests}
+	return t
+}
+
+// unsupportedInScenario returns the name of an option of the test that a scenario ignores, if any
+func (t *T) unsupportedInScenario() string {
+	o := t.options
+	switch {
+	case o.states != nil:
+		return "WithStates()/SkipStates()"
+	case o.stopAfter != nil:
+		return "StopAfter()"
+	case o.abortAfter != nil:
+		return "AbortAfter()"
+	case len(o.delayInState) != 0:
+		return "DelayInState()"
+	case o.expectError != nil:
+		return "ExpectError()"
+	case o.expectCreateRejected != nil:
+		return "ExpectCreateRejected()"
+	case o.scenario != nil:
+		return "MakeScenario()"
+	}
+
+	return ""
+}
+
+// IsScenario returns true if the test runs a scenario of concurrent workflows
+func (t *T) IsScenario() bool {
+	return t.options.scenario != nil
+}
+
+func (t *T) scenario(option string) *TScenario {
+	if t.options.scenario == nil {
+		panic(fmt.Sprintf("Test '%s' option %s requires MakeScenario()", t.name, option))
+	}
+
+	return t.options.scenario
+}
+
+// Staggered advances each workflow in the scenario the delay after the previous one, rather than
+// all at once.
+func (t *T) Staggered(delay time.Duration) *T {
+	t.scenario("Staggered").stagger = delay
+	return t
+}
+
+// ExpectWinners expects exactly the number of workflows to achieve the state, with the remaining
+// workflows losing; i.e. reporting an Error, or a TransientCondition that persists, in the state.
+// The status message of the losers must match the optional regular expression. Winners continue
+// through the remaining states while losers are torn down.
+func (t *T) ExpectWinners(state dwsv1alpha7.WorkflowState, winners int, message ...string) *T {
+	s := t.scenario("ExpectWinners")
+	if !slices.Contains(DefaultStates, state) || state == dwsv1alpha7.StateTeardown {
+		panic(fmt.Sprintf("Test '%s' cannot contend in state '%s'", t.name, state))
+	}
+	if winners < 0 || winners > len(s.tests) {
+		panic(fmt.Sprintf("Test '%s' expects %d winners of %d workflows", t.name, winners, len(s.tests)))
+	}
+
+	for _, test := range s.tests {
+		if test.options.expectTransient != nil && test.options.expectTransient.state == state {
+			panic(fmt.Sprintf("Test '%s' cannot expect a transient condition in contention state '%s'", test.Name(), state))
+		}
+	}
+
+	s.contention = &TContention{state: state, winners: winners}
+	if len(message) != 0 {
+		s.contention.message = mustCompile(t, "ExpectWinners", message[0])
+	}
+
+	return t
+}
+
+// clone duplicates the scenario's tests with the suffix
+func (s *TScenario) clone(suffix string) *TScenario {
+	dup := *s
+	dup.tests = make([]*T, len(s.tests))
+	for index, test := range s.tests {
+		dup.tests[index] = test.duplicate(suffix)
+	}
+
+	return &dup
+}
+
+func (s *TScenario) prepare(ctx context.Context, k8sClient client.Client) error {
+	for _, test := range s.tests {
+		if err := test.Prepare(ctx, k8sClient); err != nil {
+			return fmt.Errorf("test '%s': %w", test.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+func (s *TScenario) cleanup(ctx context.Context, k8sClient client.Client) error {
+	for index := len(s.tests) - 1; index >= 0; index-- {
+		if err := s.tests[index].Cleanup(ctx, k8sClient); err != nil {
+			return fmt.Errorf("test '%s': %w", s.tests[index].Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// each calls f for each test, waiting the stagger delay between them
+func (s *TScenario) each(tests []*T, f func(int, *T)) {
+	for index, test := range tests {
+		if index != 0 && s.stagger != 0 {
+			time.Sleep(s.stagger)
+		}
+
+		f(index, test)
+	}
+}
+
+// execute creates the workflows and advances them through their states in lockstep
+func (s *TScenario) execute(ctx context.Context, k8sClient client.Client) {
+	s.each(s.tests, func(_ int, test *T) {
+		workflow := test.Workflow()
+
+		By(fmt.Sprintf("Creating workflow '%s'", workflow.Name))
+		Expect(k8sClient.Create(ctx, workflow)).To(Succeed())
+
+		DeferCleanup(func(context SpecContext) {
+			if !context.SpecReport().Failed() {
+				test.EnsureTeardown(ctx, k8sClient, workflow)
+
+				Expect(k8sClient.Delete(ctx, workflow)).To(Succeed())
+			}
+		})
+	})
+
+	for _, test := range s.tests {
+		defer test.reportTimings()
+	}
+
+	active := s.tests
+	for _, state := range DefaultStates {
+		if s.contention != nil && state == s.contention.state {
+			active = s.contend(ctx, k8sClient, active)
+			continue
+		}
+
+		s.advance(ctx, k8sClient, active, state)
+	}
+}
+
+// advance advances the tests to the state at once (or staggered), and then waits for each of them
+// to achieve it.
+func (s *TScenario) advance(ctx context.Context, k8sClient client.Client, tests []*T, state dwsv1alpha7.WorkflowState) {
+	// Proposal is the initial state, so the workflows are already advancing
+	if state == dwsv1alpha7.StateProposal {
+		for _, test := range tests {
+			test.timeState(state, func() { test.proposal(ctx, k8sClient, test.Workflow()) })
+		}
+		return
+	}
+
+	advances := make([]*stateAdvance, len(tests))
+	timings := make([]func(), len(tests))
+	s.each(tests, func(index int, test *T) {
+		timings[index] = test.startTiming(state)
+		if state == dwsv1alpha7.StateSetup {
+			test.assignResources(ctx, k8sClient, test.Workflow())
+		}
+
+		advances[index] = test.advanceState(ctx, k8sClient, test.Workflow(), state)
+	})
+
+	for index, test := range tests {
+		test.waitForState(ctx, k8sClient, test.Workflow(), advances[index])
+		test.verifyState(ctx, k8sClient, test.Workflow(), state)
+		timings[index]()
+	}
+}
+
+// contend advances the tests to the contention state at once (or staggered) and returns the
+// winners, after verifying the expected number of workflows won and tearing down the losers. A
+// workflow loses when it reports an Error, or a TransientCondition that persists for
+// contentionLossPersistence, in the state. The winners are checked as though they had advanced to
+// the state alone; i.e. any rejected transitions, after hooks, and state verification.
+func (s *TScenario) contend(ctx context.Context, k8sClient client.Client, tests []*T) []*T {
+	state := s.contention.state
+
+	By(fmt.Sprintf("Contending for %s", state))
+
+	// Proposal is the initial state, so the workflows are already contending
+	advances := make([]*stateAdvance, len(tests))
+	timings := make([]func(), len(tests))
+	if state == dwsv1alpha7.StateProposal {
+		for index, test := range tests {
+			workflow := test.Workflow()
+			timings[index] = test.startTiming(state)
+			setActiveState(workflow, state)
+			test.runStateHooks(ctx, k8sClient, workflow, state, true)
+			test.checkRejectedTransitions(ctx, k8sClient, workflow, state, true)
+			advances[index] = &stateAdvance{state: state, start: workflow.CreationTimestamp.Time}
+		}
+	} else {
+		for index, test := range tests {
+			timings[index] = test.startTiming(state)
+			if state == dwsv1alpha7.StateSetup {
+				test.assignResources(ctx, k8sClient, test.Workflow())
+			}
+		}
+
+		s.each(tests, func(index int, test *T) {
+			advances[index] = test.advanceState(ctx, k8sClient, test.Workflow(), state)
+		})
+	}
+
+	winners, losers := make([]*T, 0), make([]*T, 0)
+	for index, test := range tests {
+		workflow := test.Workflow()
+		setActiveState(workflow, state)
+
+		var transientSince time.Time
+		Eventually(func() bool {
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), workflow)).Should(Succeed())
+
+			status := &workflow.Status
+			switch {
+			case status.State != state:
+				// Not yet in the state
+			case status.Ready && status.Status == dwsv1alpha7.StatusCompleted:
+				return true
+			case status.Status == dwsv1alpha7.StatusError:
+				return true
+			case status.Status == dwsv1alpha7.StatusTransientCondition:
+				if transientSince.IsZero() {
+					transientSince = time.Now()
+				}
+				return time.Since(transientSince) >= contentionLossPersistence
+			}
+
+			transientSince = time.Time{}
+			return false
+		}).
+			WithTimeout(test.getTimeout(ctx, state)).
+			WithPolling(time.Second).
+			Should(BeTrue(), func() string {
+				return fmt.Sprintf("workflow '%s' wins or loses in state '%s': %+v", workflow.Name, state, workflow.Status)
+			})
+
+		if workflow.Status.Ready {
+			winners = append(winners, test)
+
+			advance := advances[index]
+			test.recordTransition(workflow, state, advance.start.Add(advance.update), advance.update)
+			test.checkRejectedTransitions(ctx, k8sClient, workflow, state, false)
+			test.runStateHooks(ctx, k8sClient, workflow, state, false)
+			test.verifyState(ctx, k8sClient, workflow, state)
+		} else {
+			GinkgoWriter.Printf("Workflow '%s' lost in state '%s': %s\n", workflow.Name, state, workflow.Status.Message)
+			losers = append(losers, test)
+		}
+
+		timings[index]()
+	}
+
+	Expect(winners).To(HaveLen(s.contention.winners), fmt.Sprintf("winners %v, losers %v", testNames(winners), testNames(losers)))
+
+	for _, test := range losers {
+		if s.contention.message != nil {
+			Expect(test.Workflow().Status.Message).To(MatchRegexp(s.contention.message.String()), fmt.Sprintf("workflow '%s' lost for the wrong reason", test.Workflow().Name))
+		}
+
+		test.timeState(dwsv1alpha7.StateTeardown, func() { test.teardown(ctx, k8sClient, test.Workflow()) })
+	}
+
+	return winners
+}
+
+// workflows returns the test's workflow, or the workflow of each test in a scenario
+func (t *T) workflows() []*dwsv1alpha7.Workflow {
+	if !t.IsScenario() {
+		return []*dwsv1alpha7.Workflow{t.Workflow()}
+	}
+
+	workflows := make([]*dwsv1alpha7.Workflow, 0, len(t.options.scenario.tests))
+	for _, test := range t.options.scenario.tests {
+		workflows = append(workflows, test.Workflow())
+	}
+
+	return workflows
+}
+
+func testNames(tests []*T) string {
+	names := make([]string, len(tests))
+	for index, test := range tests {
+		names[index] = test.Name()
+	}
+
+	return "[" + strings.Join(names, ", ") + "]"
+}
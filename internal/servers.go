@@ -0,0 +1,297 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/gomega"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+// Allocation set labels used in the DirectiveBreakdown for Lustre file systems
+const (
+	AllocationSetMGT    = "mgt"
+	AllocationSetMDT    = "mdt"
+	AllocationSetMGTMDT = "mgtmdt"
+	AllocationSetOST    = "ost"
+)
+
+// ColocationExclusive is the colocation constraint type requiring an allocation set be placed on
+// Rabbits that do not have any other allocations with the same key.
+const ColocationExclusive = "exclusive"
+
+// serverPlacement assigns Rabbits to the allocation sets of a DirectiveBreakdown. The placement
+// policy orders the Rabbits for each allocation set, after which the Lustre constraints are
+// applied. With the default policy, placement is deterministic for a given workflow, set of
+// Rabbits, and set of Rabbits in use by other MGTs.
+type serverPlacement struct {
+	// Workflow name the servers are placed for
+	workflow string
+
+	// Policy that orders the Rabbits for each allocation set. Defaults to defaultPlacementPolicy
+	policy PlacementPolicy
+
+	// Rabbits selected for the test
+	rabbits []SelectedRabbit
+
+	// Number of computes selected for the test. Per-compute storage requires each of them be
+	// attached to one of the selected Rabbits.
+	computes int
+
+	// Rabbits that are hosting an MGT for another file system. An MGT is never placed on these
+	// Rabbits, and any MGT placed by this placement is added. See findMGTRabbits() for the limits
+	// of this under parallel processes.
+	mgtRabbits []string
+}
+
+// isExclusive returns true if the allocation set must not share a Rabbit with any other MGT.
+func isExclusive(set *dwsv1alpha7.StorageAllocationSet) bool {
+	if set.Label == AllocationSetMGT || set.Label == AllocationSetMGTMDT {
+		return true
+	}
+
+	for _, constraint := range set.Constraints.Colocation {
+		if constraint.Type == ColocationExclusive {
+			return true
+		}
+	}
+
+	return false
+}
+
+// place returns the servers allocation sets for the directive breakdown's allocation sets. Any
+// exclusive (MGT) allocation sets are placed first so the MDTs can avoid them where possible;
+// OSTs and the allocations of simple file systems can go anywhere.
+func (p *serverPlacement) place(ctx context.Context, k8sClient client.Client, sets []dwsv1alpha7.StorageAllocationSet) ([]dwsv1alpha7.ServersSpecAllocationSet, error) {
+	allocationSets := make([]dwsv1alpha7.ServersSpecAllocationSet, len(sets))
+
+	order := make([]int, 0, len(sets))
+	for index := range sets {
+		if isExclusive(&sets[index]) {
+			order = append(order, index)
+		}
+	}
+	for index := range sets {
+		if !isExclusive(&sets[index]) {
+			order = append(order, index)
+		}
+	}
+
+	// Rabbits used by the exclusive allocation sets of this file system
+	used := make([]string, 0)
+	for _, index := range order {
+		set := &sets[index]
+
+		storage, err := p.placeAllocationSet(ctx, k8sClient, set, used)
+		if err != nil {
+			return nil, err
+		}
+
+		if isExclusive(set) {
+			for _, s := range storage {
+				used = append(used, s.Name)
+				p.mgtRabbits = append(p.mgtRabbits, s.Name)
+			}
+		}
+
+		allocationSets[index] = dwsv1alpha7.ServersSpecAllocationSet{
+			AllocationSize: set.MinimumCapacity,
+			Label:          set.Label,
+			Storage:        storage,
+		}
+	}
+
+	return allocationSets, nil
+}
+
+func (p *serverPlacement) placeAllocationSet(ctx context.Context, k8sClient client.Client, set *dwsv1alpha7.StorageAllocationSet, used []string) ([]dwsv1alpha7.ServersSpecStorage, error) {
+	storages := make([]dwsv1alpha7.ServersSpecStorage, 0)
+
+	switch set.AllocationStrategy {
+	case dwsv1alpha7.AllocatePerCompute:
+		// Make one allocation per selected compute node on the Rabbit it is attached to
+		attached := 0
+		for _, rabbit := range p.rabbits {
+			if rabbit.Computes != 0 {
+				storages = append(storages, dwsv1alpha7.ServersSpecStorage{Name: rabbit.Name, AllocationCount: rabbit.Computes})
+				attached += rabbit.Computes
+			}
+		}
+
+		if len(storages) == 0 || attached < p.computes {
+			return nil, fmt.Errorf("allocation set '%s': %d of %d selected computes are not attached to the selected rabbits; per-compute storage must be on the rabbit of each compute", set.Label, p.computes-attached, p.computes)
+		}
+
+		return storages, nil
+
+	case dwsv1alpha7.AllocateAcrossServers, dwsv1alpha7.AllocateSingleServer:
+		candidates, err := p.candidates(ctx, k8sClient, set, used)
+		if err != nil {
+			return nil, err
+		}
+
+		// Make one allocation per Rabbit, limited to the constraint count if provided, or one
+		// allocation total
+		count := len(candidates)
+		if set.AllocationStrategy == dwsv1alpha7.AllocateSingleServer {
+			count = 1
+		} else if set.Constraints.Count > 0 {
+			count = set.Constraints.Count
+		}
+
+		if len(candidates) == 0 && isExclusive(set) {
+			return nil, fmt.Errorf("allocation set '%s': MGT exclusivity exhausted; every selected rabbit already hosts an MGT of another file system %v", set.Label, p.mgtRabbits)
+		}
+
+		if count > len(candidates) || len(candidates) == 0 {
+			return nil, fmt.Errorf("allocation set '%s': requires %d rabbit(s) but only %v are available", set.Label, count, candidates)
+		}
+
+		for _, name := range candidates[:count] {
+			storages = append(storages, dwsv1alpha7.ServersSpecStorage{Name: name, AllocationCount: 1})
+		}
+
+		return storages, nil
+	}
+
+	return storages, nil
+}
+
+// candidates returns the Rabbits an allocation set may be placed on in order of preference,
+// as ordered by the placement policy. Exclusive allocation sets exclude the Rabbits hosting
+// another MGT, and single server allocation sets prefer Rabbits not used by the exclusive
+// allocation sets of this file system (i.e. an MDT is placed away from its MGT when possible).
+func (p *serverPlacement) candidates(ctx context.Context, k8sClient client.Client, set *dwsv1alpha7.StorageAllocationSet, used []string) ([]string, error) {
+	policy := p.policy
+	if policy == nil {
+		policy = defaultPlacementPolicy
+	}
+
+	names := make([]string, len(p.rabbits))
+	for index, rabbit := range p.rabbits {
+		names[index] = rabbit.Name
+	}
+
+	ordered, err := policy.Order(ctx, k8sClient, PlacementRequest{Workflow: p.workflow, AllocationSet: set, Rabbits: names})
+	if err != nil {
+		return nil, fmt.Errorf("allocation set '%s': %w", set.Label, err)
+	}
+
+	rabbits := make([]string, 0, len(ordered))
+	for _, rabbit := range ordered {
+		if isExclusive(set) && slices.Contains(p.mgtRabbits, rabbit) {
+			continue
+		}
+
+		rabbits = append(rabbits, rabbit)
+	}
+
+	if set.AllocationStrategy == dwsv1alpha7.AllocateSingleServer {
+		slices.SortStableFunc(rabbits, func(a, b string) int {
+			return boolCompare(slices.Contains(used, a), slices.Contains(used, b))
+		})
+	}
+
+	return rabbits, nil
+}
+
+func boolCompare(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+
+	return -1
+}
+
+// findMGTRabbits returns the Rabbits hosting the MGT of any file system in the cluster other
+// than those of the provided servers resource. The Servers of a workflow that is being torn down
+// or deleted are ignored, as its MGT is about to go away. This is a snapshot of the Servers
+// resources; two parallel Ginkgo processes (-p) placing MGTs at the same time do not see each
+// other's placement, so MGT exclusivity across processes is best-effort.
+func findMGTRabbits(ctx context.Context, k8sClient client.Client, servers *dwsv1alpha7.Servers) []string {
+	serversList := &dwsv1alpha7.ServersList{}
+	Expect(k8sClient.List(ctx, serversList)).To(Succeed())
+
+	workflows := &dwsv1alpha7.WorkflowList{}
+	Expect(k8sClient.List(ctx, workflows)).To(Succeed())
+
+	return mgtRabbits(serversList.Items, workflows.Items, servers)
+}
+
+// mgtRabbits returns the Rabbits hosting an MGT in the servers, other than the excluded servers
+// and those of workflows being torn down or deleted.
+func mgtRabbits(serversList []dwsv1alpha7.Servers, workflows []dwsv1alpha7.Workflow, exclude *dwsv1alpha7.Servers) []string {
+	departing := make(map[string]bool)
+	for _, workflow := range workflows {
+		if !workflow.DeletionTimestamp.IsZero() || workflow.Spec.DesiredState == dwsv1alpha7.StateTeardown {
+			departing[workflow.Namespace+"/"+workflow.Name] = true
+		}
+	}
+
+	rabbits := make([]string, 0)
+	for _, s := range serversList {
+		if client.ObjectKeyFromObject(&s) == client.ObjectKeyFromObject(exclude) || !s.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if departing[s.Labels[dwsv1alpha7.WorkflowNamespaceLabel]+"/"+s.Labels[dwsv1alpha7.WorkflowNameLabel]] {
+			continue
+		}
+
+		for _, allocationSet := range s.Spec.AllocationSets {
+			if allocationSet.Label != AllocationSetMGT && allocationSet.Label != AllocationSetMGTMDT {
+				continue
+			}
+
+			for _, storage := range allocationSet.Storage {
+				if !slices.Contains(rabbits, storage.Name) {
+					rabbits = append(rabbits, storage.Name)
+				}
+			}
+		}
+	}
+
+	return rabbits
+}
+
+// placementString formats the servers allocation sets for logging.
+func placementString(allocationSets []dwsv1alpha7.ServersSpecAllocationSet) string {
+	placements := make([]string, len(allocationSets))
+	for index, allocationSet := range allocationSets {
+		storages := make([]string, len(allocationSet.Storage))
+		for i, storage := range allocationSet.Storage {
+			storages[i] = fmt.Sprintf("%s(%d)", storage.Name, storage.AllocationCount)
+		}
+
+		placements[index] = fmt.Sprintf("%s: %s", allocationSet.Label, strings.Join(storages, ", "))
+	}
+
+	return strings.Join(placements, "; ")
+}
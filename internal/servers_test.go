@@ -0,0 +1,151 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+func lustreAllocationSets() []dwsv1alpha7.StorageAllocationSet {
+	return []dwsv1alpha7.StorageAllocationSet{
+		{Label: AllocationSetOST, AllocationStrategy: dwsv1alpha7.AllocateAcrossServers, MinimumCapacity: 100},
+		{Label: AllocationSetMDT, AllocationStrategy: dwsv1alpha7.AllocateSingleServer, MinimumCapacity: 10},
+		{Label: AllocationSetMGT, AllocationStrategy: dwsv1alpha7.AllocateSingleServer, MinimumCapacity: 1,
+			Constraints: dwsv1alpha7.AllocationSetConstraints{
+				Colocation: []dwsv1alpha7.AllocationSetColocationConstraint{{Type: ColocationExclusive, Key: "lustre-mgt"}},
+			}},
+	}
+}
+
+func TestServerPlacement(t *testing.T) {
+	nodes, _ := selectNodes(makeSystemConfiguration(3, 2), nil)
+
+	placement := serverPlacement{workflow: "lustre", rabbits: nodes.Rabbits, mgtRabbits: []string{"rabbit-0"}}
+	allocationSets, err := placement.place(context.TODO(), nil, lustreAllocationSets())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	labels := []string{allocationSets[0].Label, allocationSets[1].Label, allocationSets[2].Label}
+	if !reflect.DeepEqual(labels, []string{AllocationSetOST, AllocationSetMDT, AllocationSetMGT}) {
+		t.Errorf("allocation set order not preserved: %v", labels)
+	}
+
+	if len(allocationSets[0].Storage) != 3 {
+		t.Errorf("expected OSTs on every rabbit: %s", placementString(allocationSets))
+	}
+
+	mgt, mdt := allocationSets[2].Storage[0].Name, allocationSets[1].Storage[0].Name
+	if mgt == "rabbit-0" {
+		t.Errorf("MGT placed on a rabbit hosting another MGT: %s", placementString(allocationSets))
+	}
+	if mgt == mdt {
+		t.Errorf("MDT placed with the MGT: %s", placementString(allocationSets))
+	}
+	if !slices.Contains(placement.mgtRabbits, mgt) {
+		t.Errorf("MGT rabbit '%s' not reserved: %v", mgt, placement.mgtRabbits)
+	}
+
+	// The placement is deterministic
+	again := serverPlacement{workflow: "lustre", rabbits: nodes.Rabbits, mgtRabbits: []string{"rabbit-0"}}
+	allocationSetsAgain, _ := again.place(context.TODO(), nil, lustreAllocationSets())
+	if !reflect.DeepEqual(allocationSets, allocationSetsAgain) {
+		t.Errorf("placement is not deterministic: %s vs %s", placementString(allocationSets), placementString(allocationSetsAgain))
+	}
+
+	// A second file system cannot place an MGT when all rabbits host an MGT
+	full := serverPlacement{workflow: "lustre", rabbits: nodes.Rabbits, mgtRabbits: []string{"rabbit-0", "rabbit-1", "rabbit-2"}}
+	if _, err := full.place(context.TODO(), nil, lustreAllocationSets()); err == nil || !strings.Contains(err.Error(), "exhausted") {
+		t.Errorf("expected an exhausted error when no rabbit is available for the MGT: %v", err)
+	}
+}
+
+func TestPerComputePlacement(t *testing.T) {
+	systemConfig := makeSystemConfiguration(3, 2)
+	sets := []dwsv1alpha7.StorageAllocationSet{{Label: "xfs", AllocationStrategy: dwsv1alpha7.AllocatePerCompute, MinimumCapacity: 100}}
+
+	nodes, _ := selectNodes(systemConfig, &TNodeSelection{computeCount: 3})
+	placement := serverPlacement{workflow: "xfs", rabbits: nodes.Rabbits, computes: len(nodes.Computes)}
+	allocationSets, err := placement.place(context.TODO(), nil, sets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	count := 0
+	for _, storage := range allocationSets[0].Storage {
+		count += storage.AllocationCount
+	}
+	if count != 3 {
+		t.Errorf("expected one allocation per compute: %s", placementString(allocationSets))
+	}
+
+	// Computes on other rabbits cannot use per-compute storage
+	for _, selection := range []*TNodeSelection{
+		{rabbits: []string{"rabbit-0"}, computesOnRabbits: []string{"rabbit-1"}},
+		{rabbits: []string{"rabbit-0"}, computesOnRabbits: []string{"rabbit-0", "rabbit-1"}},
+	} {
+		nodes, _ := selectNodes(systemConfig, selection)
+		placement := serverPlacement{workflow: "xfs", rabbits: nodes.Rabbits, computes: len(nodes.Computes)}
+		if _, err := placement.place(context.TODO(), nil, sets); err == nil {
+			t.Errorf("expected an error for computes not attached to rabbits %v", selection.rabbits)
+		}
+	}
+}
+
+func TestMGTRabbits(t *testing.T) {
+	mgt := func(name, workflow, rabbit string) dwsv1alpha7.Servers {
+		return dwsv1alpha7.Servers{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{
+				dwsv1alpha7.WorkflowNameLabel:      workflow,
+				dwsv1alpha7.WorkflowNamespaceLabel: "default",
+			}},
+			Spec: dwsv1alpha7.ServersSpec{AllocationSets: []dwsv1alpha7.ServersSpecAllocationSet{
+				{Label: AllocationSetMGT, Storage: []dwsv1alpha7.ServersSpecStorage{{Name: rabbit, AllocationCount: 1}}},
+			}},
+		}
+	}
+
+	now := metav1.Now()
+	workflows := []dwsv1alpha7.Workflow{
+		{ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "default"}, Spec: dwsv1alpha7.WorkflowSpec{DesiredState: dwsv1alpha7.StatePreRun}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "teardown", Namespace: "default"}, Spec: dwsv1alpha7.WorkflowSpec{DesiredState: dwsv1alpha7.StateTeardown}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "deleting", Namespace: "default", DeletionTimestamp: &now}, Spec: dwsv1alpha7.WorkflowSpec{DesiredState: dwsv1alpha7.StateDataOut}},
+	}
+
+	servers := []dwsv1alpha7.Servers{
+		mgt("running-0", "running", "rabbit-0"),
+		mgt("teardown-0", "teardown", "rabbit-1"),
+		mgt("deleting-0", "deleting", "rabbit-2"),
+		mgt("self-0", "self", "rabbit-3"),
+	}
+
+	rabbits := mgtRabbits(servers, workflows, &servers[3])
+	if !reflect.DeepEqual(rabbits, []string{"rabbit-0"}) {
+		t.Errorf("expected only the MGT of the running workflow, got %v", rabbits)
+	}
+}
@@ -0,0 +1,349 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+// SoakPolicy repeats a test for a number of iterations and/or a duration, whichever is reached
+// first. By default the soak stops at the first failed iteration, leaving the workflow in place
+// for triage; with ContinueOnFailure the failed iteration is cleaned up and the soak carries on.
+type SoakPolicy struct {
+	Iterations        int
+	Duration          time.Duration
+	ContinueOnFailure bool
+}
+
+// SuiteStartContextKey is the context key for the time the suite started. Ginkgo's suite timeout
+// runs from this time, so a soak only has what is left of it.
+const SuiteStartContextKey = "suiteStart"
+
+// soakIterationMargin allows for the last iteration of a duration soak, which may start just
+// before the duration expires, and for reporting the summary.
+const soakIterationMargin = 30 * time.Minute
+
+// budget returns the time a duration soak may take, or zero for an iteration count soak, whose
+// length cannot be known in advance.
+func (p SoakPolicy) budget() time.Duration {
+	if p.Duration == 0 {
+		return 0
+	}
+
+	return p.Duration + soakIterationMargin
+}
+
+// fitsTimeout returns an error if the number of soaks do not fit within the time left of the suite
+// timeout. Ginkgo interrupts the suite at its timeout (1h by default), which would kill a longer
+// soak before it reports its summary, so fail early with the `--timeout` to use instead.
+func (p SoakPolicy) fitsTimeout(soaks int, remaining time.Duration) error {
+	needed := time.Duration(soaks) * p.budget()
+	if needed <= remaining {
+		return nil
+	}
+
+	return fmt.Errorf("%d soak(s) of %v, plus %v for the last iteration of each, need %v but only %v of the suite timeout is left; run with `ginkgo run --timeout=%v` or longer",
+		soaks, p.Duration, soakIterationMargin, needed, remaining.Round(time.Second), needed+time.Hour)
+}
+
+// SoaksFitTimeout returns an error if the soaks of the specs that will run do not fit within the
+// suite timeout. It is an estimate, as every spec is assumed to soak.
+func (p SoakPolicy) SoaksFitTimeout(specs int) error {
+	suiteConfig, _ := GinkgoConfiguration()
+	return p.fitsTimeout(specs, suiteConfig.Timeout)
+}
+
+// suiteTimeLeft returns the time left before Ginkgo's suite timeout interrupts the suite
+func suiteTimeLeft(ctx context.Context) time.Duration {
+	suiteConfig, _ := GinkgoConfiguration()
+	if start, ok := ctx.Value(SuiteStartContextKey).(time.Time); ok {
+		return suiteConfig.Timeout - time.Since(start)
+	}
+
+	return suiteConfig.Timeout
+}
+
+// ParseSoakPolicy returns the soak policy for a comma separated list of an iteration count, a
+// duration, and "continue" to keep going after a failure; e.g. "100", "8h,continue" or
+// "500,12h". An empty value returns nil, which disables soaking.
+func ParseSoakPolicy(value string) (*SoakPolicy, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	policy := &SoakPolicy{}
+	for _, field := range strings.Split(value, ",") {
+		if field == "continue" {
+			policy.ContinueOnFailure = true
+		} else if iterations, err := strconv.Atoi(field); err == nil && iterations > 0 {
+			policy.Iterations = iterations
+		} else if duration, err := time.ParseDuration(field); err == nil && duration > 0 {
+			policy.Duration = duration
+		} else {
+			return nil, fmt.Errorf("invalid soak policy '%s': expected an iteration count, a duration or 'continue'", field)
+		}
+	}
+
+	if policy.Iterations == 0 && policy.Duration == 0 {
+		return nil, fmt.Errorf("invalid soak policy '%s': an iteration count or duration is required", value)
+	}
+
+	return policy, nil
+}
+
+func (p SoakPolicy) String() string {
+	fields := make([]string, 0)
+	if p.Iterations != 0 {
+		fields = append(fields, strconv.Itoa(p.Iterations))
+	}
+	if p.Duration != 0 {
+		fields = append(fields, p.Duration.String())
+	}
+	if p.ContinueOnFailure {
+		fields = append(fields, "continue")
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// done returns true if the soak has run the iterations or for the duration
+func (p SoakPolicy) done(iterations int, elapsed time.Duration) bool {
+	return (p.Iterations != 0 && iterations >= p.Iterations) || (p.Duration != 0 && elapsed >= p.Duration)
+}
+
+// Soak repeats the test according to the policy. Each iteration runs a copy of the test with a
+// unique name, from Prepare() through Cleanup().
+func (t *T) Soak(policy SoakPolicy) *T {
+	if t.IsScenario() {
+		panic(fmt.Sprintf("Test '%s' cannot soak a scenario", t.name))
+	}
+	if policy.Iterations <= 0 && policy.Duration <= 0 {
+		panic(fmt.Sprintf("Test '%s' soak requires an iteration count or duration", t.name))
+	}
+
+	t.options.soak = &policy
+	return t.WithLabels("soak")
+}
+
+// IsSoak returns true if the test is repeated with Soak()
+func (t *T) IsSoak() bool {
+	return t.options.soak != nil
+}
+
+// SoakIteration is the result of a single iteration of a soak
+type SoakIteration struct {
+	Iteration int           `json:"iteration"`
+	Workflow  string        `json:"workflow"`
+	Passed    bool          `json:"passed"`
+	Failure   string        `json:"failure,omitempty"`
+	Duration  time.Duration `json:"duration"`
+	Timings   []StateTiming `json:"timings"`
+}
+
+// SoakStats are the results of every iteration of a soak
+type SoakStats struct {
+	Test       string          `json:"test"`
+	Policy     string          `json:"policy"`
+	Iterations []SoakIteration `json:"iterations"`
+}
+
+// Failures returns the number of failed iterations
+func (s *SoakStats) Failures() int {
+	failures := 0
+	for _, iteration := range s.Iterations {
+		if !iteration.Passed {
+			failures++
+		}
+	}
+
+	return failures
+}
+
+// percentile returns the nearest-rank percentile of the sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// Summary returns the failure rate and the latency percentiles of each state over the iterations
+func (s *SoakStats) Summary() string {
+	total := len(s.Iterations)
+	failures := s.Failures()
+
+	rate := 0.0
+	if total != 0 {
+		rate = 100 * float64(failures) / float64(total)
+	}
+
+	latencies := make(map[dwsv1alpha7.WorkflowState][]time.Duration)
+	for _, iteration := range s.Iterations {
+		for _, timing := range iteration.Timings {
+			latencies[timing.State] = append(latencies[timing.State], timing.Duration)
+		}
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Soak '%s' (%s): %d iterations, %d failed (%.1f%%)\n", s.Test, s.Policy, total, failures, rate)
+	fmt.Fprintf(b, "%-10s %8s %10s %10s %10s %10s\n", "State", "Count", "p50", "p90", "p99", "Max")
+	for _, state := range DefaultStates {
+		durations := latencies[state]
+		if len(durations) == 0 {
+			continue
+		}
+
+		slices.Sort(durations)
+		fmt.Fprintf(b, "%-10s %8d %10s %10s %10s %10s\n", state, len(durations),
+			percentile(durations, 50).Round(time.Millisecond),
+			percentile(durations, 90).Round(time.Millisecond),
+			percentile(durations, 99).Round(time.Millisecond),
+			durations[len(durations)-1].Round(time.Millisecond))
+	}
+
+	for _, iteration := range s.Iterations {
+		if !iteration.Passed {
+			fmt.Fprintf(b, "Iteration %d (%s) failed: %s\n", iteration.Iteration, iteration.Workflow, iteration.Failure)
+		}
+	}
+
+	return b.String()
+}
+
+// RunSoak runs the iterations of the soak and reports the summary. The spec fails at the first
+// failed iteration, or after all iterations when continuing on failure.
+func (t *T) RunSoak(ctx context.Context, k8sClient client.Client) *SoakStats {
+	policy := *t.options.soak
+	stats := &SoakStats{Test: t.name, Policy: policy.String()}
+
+	Expect(policy.fitsTimeout(1, suiteTimeLeft(ctx))).To(Succeed(), fmt.Sprintf("soak of '%s'", t.name))
+
+	DeferCleanup(func() {
+		summary := stats.Summary()
+		GinkgoWriter.Print(summary)
+		AddReportEntry(fmt.Sprintf("Soak Summary for '%s'", t.name), summary)
+	})
+
+	start := time.Now()
+	for index := 0; !policy.done(index, time.Since(start)); index++ {
+		iteration := t.duplicate(fmt.Sprintf("-soak-%d", index))
+		iteration.options.soak = nil
+
+		By(fmt.Sprintf("Soak iteration %d: %s", index, iteration.Name()))
+		iterationStart := time.Now()
+		events := iteration.RecordEvents(ctx, k8sClient)
+		err := InterceptGomegaFailure(func() { iteration.runIteration(ctx, k8sClient) })
+		timeline := events.Stop(ctx)
+
+		result := SoakIteration{
+			Iteration: index,
+			Workflow:  iteration.Workflow().Name,
+			Passed:    err == nil,
+			Duration:  time.Since(iterationStart),
+			Timings:   iteration.StateTimings(),
+		}
+		if err != nil {
+			result.Failure = err.Error()
+		}
+		stats.Iterations = append(stats.Iterations, result)
+
+		if err == nil {
+			continue
+		}
+
+		reportSoakFailure(ctx, k8sClient, iteration, index, iterationStart, timeline)
+
+		if !policy.ContinueOnFailure {
+			Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("soak iteration %d of '%s'", index, t.name))
+		}
+
+		// Clean up as best as possible so the next iteration has a chance of passing
+		if err := InterceptGomegaFailure(func() { iteration.cleanupIteration(ctx, k8sClient) }); err != nil {
+			GinkgoWriter.Printf("Failed to clean up soak iteration %d: %v\n", index, err)
+		}
+	}
+
+	Expect(stats.Failures()).To(BeZero(), fmt.Sprintf("soak of '%s' had failed iterations", t.name))
+
+	return stats
+}
+
+// runIteration runs a soak iteration from Prepare() through Cleanup(), in the same way as a spec
+func (t *T) runIteration(ctx context.Context, k8sClient client.Client) {
+	Expect(t.Prepare(ctx, k8sClient)).To(Succeed())
+
+	if t.ExpectsCreateRejected() {
+		t.VerifyCreateRejected(ctx, k8sClient)
+	} else {
+		Expect(k8sClient.Create(ctx, t.Workflow())).To(Succeed())
+		t.Execute(ctx, k8sClient)
+	}
+
+	t.cleanupIteration(ctx, k8sClient)
+}
+
+// cleanupIteration tears down and deletes the iteration's workflow, if any, and cleans up the
+// test options.
+func (t *T) cleanupIteration(ctx context.Context, k8sClient client.Client) {
+	workflow := t.Workflow()
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), workflow); err == nil {
+		if t.ShouldTeardown() {
+			t.EnsureTeardown(ctx, k8sClient, workflow)
+		}
+
+		Expect(k8sClient.Delete(ctx, workflow)).To(Succeed())
+		WaitForDeletion(ctx, k8sClient, workflow)
+	}
+
+	Expect(t.Cleanup(ctx, k8sClient)).To(Succeed())
+}
+
+// reportSoakFailure adds the diagnostics of a failed iteration to the spec report, including the
+// iteration's events and the controller logs since the iteration started. The spec's own reporting
+// does not cover the iterations, whose workflows are named for the iteration.
+func reportSoakFailure(ctx context.Context, k8sClient client.Client, t *T, index int, start time.Time, timeline EventTimeline) {
+	workflow := t.Workflow()
+	AddReportEntry(fmt.Sprintf("Soak iteration %d: Workflow '%s' Failed", index, workflow.Name), workflow.Status)
+	AddReportEntry(fmt.Sprintf("Soak iteration %d: Diagnostics for '%s'", index, workflow.Name),
+		ReportDiagnostics(ctx, k8sClient, workflow))
+
+	t.ReportEvents(ctx, timeline, true)
+	t.ReportControllerLogs(ctx, k8sClient, start, time.Now())
+
+	if t.HasContainerDirective() {
+		AddReportEntry(fmt.Sprintf("Soak iteration %d: Container Pod Logs for '%s'", index, workflow.Name),
+			ReportContainerPodLogs(ctx, k8sClient, workflow))
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+func TestParseSoakPolicy(t *testing.T) {
+	policy, err := ParseSoakPolicy("500,12h,continue")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if policy.Iterations != 500 || policy.Duration != 12*time.Hour || !policy.ContinueOnFailure {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+	if policy.String() != "500,12h0m0s,continue" {
+		t.Errorf("unexpected policy string '%s'", policy)
+	}
+
+	if !policy.done(500, time.Minute) || !policy.done(1, 12*time.Hour) || policy.done(499, time.Hour) {
+		t.Errorf("unexpected done for %+v", policy)
+	}
+
+	if policy, err := ParseSoakPolicy(""); policy != nil || err != nil {
+		t.Errorf("expected no policy: %+v %v", policy, err)
+	}
+
+	for _, value := range []string{"continue", "0", "-1h", "forever"} {
+		if _, err := ParseSoakPolicy(value); err == nil {
+			t.Errorf("expected an error for '%s'", value)
+		}
+	}
+
+	if err := policy.fitsTimeout(1, time.Hour); err == nil || !strings.Contains(err.Error(), "--timeout=13h30m0s") {
+		t.Errorf("expected the 12h soak not to fit the default suite timeout: %v", err)
+	}
+	if err := policy.fitsTimeout(1, 12*time.Hour+time.Minute); err == nil {
+		t.Errorf("expected no room for the last iteration of the 12h soak")
+	}
+	if err := policy.fitsTimeout(1, 13*time.Hour); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := policy.fitsTimeout(3, 13*time.Hour); err == nil || !strings.Contains(err.Error(), "--timeout=38h30m0s") {
+		t.Errorf("expected three 12h soaks not to fit a 13h suite timeout: %v", err)
+	}
+	if err := (SoakPolicy{Iterations: 100}).fitsTimeout(10, time.Hour); err != nil {
+		t.Errorf("unexpected error for an iteration count: %v", err)
+	}
+}
+
+func TestSoakSummary(t *testing.T) {
+	stats := &SoakStats{Test: "XFS", Policy: "10"}
+	for index := 0; index < 10; index++ {
+		iteration := SoakIteration{
+			Iteration: index,
+			Passed:    index != 3,
+			Timings: []StateTiming{
+				{State: dwsv1alpha7.StateProposal, Duration: time.Duration(index+1) * time.Second},
+			},
+		}
+		if !iteration.Passed {
+			iteration.Failure = "timeout"
+		}
+		stats.Iterations = append(stats.Iterations, iteration)
+	}
+
+	if stats.Failures() != 1 {
+		t.Errorf("expected 1 failure, got %d", stats.Failures())
+	}
+
+	summary := stats.Summary()
+	for _, expected := range []string{"10 iterations, 1 failed (10.0%)", "Proposal         10         5s         9s        10s        10s", "Iteration 3 () failed: timeout"} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("summary does not contain '%s':\n%s", expected, summary)
+		}
+	}
+	if strings.Contains(summary, "Setup") {
+		t.Errorf("summary contains a state that was not executed:\n%s", summary)
+	}
+}
@@ -22,10 +22,7 @@ package internal
 import (
 	"context"
 	"fmt"
-	"math/rand"
-	"reflect"
-	"runtime"
-	"strings"
+	"slices"
 	"time"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -43,14 +40,110 @@ import (
 // StateHandler defines a method that handles a particular state in the workflow
 type StateHandler func(context.Context, client.Client, *dwsv1alpha7.Workflow)
 
+// TState is an entry in the ordered table of workflow states a test executes. The handler
+// advances the workflow to the state and verifies the result; a nil handler selects the default
+// handler for the state.
+type TState struct {
+	State   dwsv1alpha7.WorkflowState
+	Handler StateHook
+}
+
+// DefaultStates is the sequence of workflow states a test executes unless customized with
+// WithStates() or WithStateSequence().
+var DefaultStates = []dwsv1alpha7.WorkflowState{
+	dwsv1alpha7.StateProposal,
+	dwsv1alpha7.StateSetup,
+	dwsv1alpha7.StateDataIn,
+	dwsv1alpha7.StatePreRun,
+	dwsv1alpha7.StatePostRun,
+	dwsv1alpha7.StateDataOut,
+	dwsv1alpha7.StateTeardown,
+}
+
+// defaultStateHandlers returns the default handler for each workflow state
+func (t *T) defaultStateHandlers() map[dwsv1alpha7.WorkflowState]StateHandler {
+	return map[dwsv1alpha7.WorkflowState]StateHandler{
+		dwsv1alpha7.StateProposal: t.proposal,
+		dwsv1alpha7.StateSetup:    t.setup,
+		dwsv1alpha7.StateDataIn:   t.dataIn,
+		dwsv1alpha7.StatePreRun:   t.preRun,
+		dwsv1alpha7.StatePostRun:  t.postRun,
+		dwsv1alpha7.StateDataOut:  t.dataOut,
+		dwsv1alpha7.StateTeardown: t.teardown,
+	}
+}
+
+// States returns the table of workflow states the test executes, in order
+func (t *T) States() []TState {
+	if t.options.states != nil {
+		return append([]TState{}, t.options.states...)
+	}
+
+	states := make([]TState, len(DefaultStates))
+	for index, state := range DefaultStates {
+		states[index] = TState{State: state}
+	}
+
+	return states
+}
+
+// WithStates replaces the table of workflow states the test executes. This allows custom
+// sequences, such as skipping DataIn, jumping from PreRun straight to Teardown, or repeating a
+// state transition, along with custom handlers for a state. StopAfter(), DelayInState() and the
+// state hooks apply to the states as they are executed from the table.
+func (t *T) WithStates(states ...TState) *T {
+	for _, state := range states {
+		if !slices.Contains(DefaultStates, state.State) {
+			panic(fmt.Sprintf("Test '%s' has an unknown state '%s'", t.name, state.State))
+		}
+	}
+
+	t.options.states = append([]TState{}, states...)
+	return t
+}
+
+// WithStateSequence replaces the table of workflow states with the sequence of states, each
+// using the default handler for the state.
+func (t *T) WithStateSequence(states ...dwsv1alpha7.WorkflowState) *T {
+	table := make([]TState, len(states))
+	for index, state := range states {
+		table[index] = TState{State: state}
+	}
+
+	return t.WithStates(table...)
+}
+
+// SkipStates removes the states from the table of workflow states the test executes
+func (t *T) SkipStates(states ...dwsv1alpha7.WorkflowState) *T {
+	table := slices.DeleteFunc(t.States(), func(s TState) bool { return slices.Contains(states, s.State) })
+	return t.WithStates(table...)
+}
+
 func (t *T) Execute(ctx context.Context, k8sClient client.Client) {
-	for _, fn := range []StateHandler{t.proposal, t.setup, t.dataIn, t.preRun, t.postRun, t.dataOut, t.teardown} {
-		fn(ctx, k8sClient, t.workflow)
+	// The workflow was never created; see VerifyCreateRejected()
+	if t.ExpectsCreateRejected() {
+		return
+	}
+
+	if t.IsScenario() {
+		t.options.scenario.execute(ctx, k8sClient)
+		return
+	}
 
-		// Extract the current state name from the function
-		fnName := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name() // This will return something like `full-import-path.(*T).Function-fm`
-		fnName = fnName[strings.Index(fnName, "(*T).")+5 : len(fnName)-3] // Extract the function name
-		state := dwsv1alpha7.WorkflowState(strings.Title(fnName))
+	handlers := t.defaultStateHandlers()
+
+	defer t.reportTimings()
+
+	for _, entry := range t.States() {
+		state := entry.State
+
+		t.timeState(state, func() {
+			if entry.Handler != nil {
+				entry.Handler(ctx, k8sClient, t, t.workflow)
+			} else {
+				handlers[state](ctx, k8sClient, t.workflow)
+			}
+		})
 
 		// Handle DelayInState - check all delays for this state
 		for _, delay := range t.options.delayInState {
@@ -60,6 +153,14 @@ func (t *T) Execute(ctx context.Context, k8sClient client.Client) {
 			}
 		}
 
+		// Handle AbortAfter
+		if t.options.abortAfter != nil && state == t.options.abortAfter.state {
+			By(fmt.Sprintf("Aborting after state %s", state))
+			t.timeState(dwsv1alpha7.StateTeardown, func() { handlers[dwsv1alpha7.StateTeardown](ctx, k8sClient, t.workflow) })
+			VerifyCleanTeardown(ctx, k8sClient, t, t.workflow)
+			break
+		}
+
 		// Handle StopAfter
 		if t.options.stopAfter != nil {
 			if state == t.options.stopAfter.state {
@@ -69,21 +170,57 @@ func (t *T) Execute(ctx context.Context, k8sClient client.Client) {
 	}
 }
 
+// timeState times the state's handler. The handler fills in the details of the transition; see
+// recordTransition().
+func (t *T) timeState(state dwsv1alpha7.WorkflowState, f func()) {
+	done := t.startTiming(state)
+	f()
+	done()
+}
+
+// startTiming starts timing the state and returns the function that stops it, for states whose
+// work is not a single handler (e.g. the lockstep states of a scenario).
+func (t *T) startTiming(state dwsv1alpha7.WorkflowState) func() {
+	start := time.Now()
+	t.timings = append(t.timings, StateTiming{State: state})
+	index := len(t.timings) - 1
+
+	return func() { t.timings[index].Duration = time.Since(start) }
+}
+
 func (t *T) proposal(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow) {
+	setActiveState(workflow, dwsv1alpha7.StateProposal)
+	t.runStateHooks(ctx, k8sClient, workflow, dwsv1alpha7.StateProposal, true)
+	t.checkRejectedTransitions(ctx, k8sClient, workflow, dwsv1alpha7.StateProposal, true)
+
 	// We're not ready to advance out of proposal yet, but check for expected error
 	if t.options.expectError != nil && t.options.expectError.state == dwsv1alpha7.StateProposal {
 		By("Waiting for Error status")
-		waitForError(ctx, k8sClient, workflow, dwsv1alpha7.StateProposal)
-		return
+		waitForError(ctx, k8sClient, workflow, t.options.expectError, t.getErrorTimeout(ctx, dwsv1alpha7.StateProposal))
+	} else {
+		waitForReady(ctx, k8sClient, workflow, dwsv1alpha7.StateProposal, t.getTimeout(ctx, dwsv1alpha7.StateProposal))
 	}
 
-	waitForReady(ctx, k8sClient, workflow, dwsv1alpha7.StateProposal)
+	t.recordTransition(workflow, dwsv1alpha7.StateProposal, workflow.CreationTimestamp.Time, 0)
+	t.checkRejectedTransitions(ctx, k8sClient, workflow, dwsv1alpha7.StateProposal, false)
+	t.runStateHooks(ctx, k8sClient, workflow, dwsv1alpha7.StateProposal, false)
 }
 
 func (t *T) setup(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow) {
+	t.assignResources(ctx, k8sClient, workflow)
+	t.AdvanceStateAndWaitForReady(ctx, k8sClient, workflow, dwsv1alpha7.StateSetup)
+}
 
+// assignResources assigns the computes and servers to the workflow, as is done by the WLM prior
+// to advancing to Setup.
+func (t *T) assignResources(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow) {
 	systemConfig := GetSystemConfiguraton(ctx, k8sClient)
 
+	// Select the subset of computes and Rabbits this test runs on
+	nodes, err := selectNodes(systemConfig, t.options.nodes)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(nodes.Rabbits).NotTo(BeEmpty())
+
 	By("Assigns Computes")
 	{
 		// Assign Compute Resources (only if jobdw or persistentdw is present in workflow())
@@ -95,8 +232,8 @@ func (t *T) setup(ctx context.Context, k8sClient client.Client, workflow *dwsv1a
 		Expect(computes.Data).To(HaveLen(0))
 
 		computes.Data = make([]dwsv1alpha7.ComputesData, 0)
-		for _, nodeName := range systemConfig.Computes() {
-			computes.Data = append(computes.Data, dwsv1alpha7.ComputesData{Name: *nodeName})
+		for _, nodeName := range nodes.Computes {
+			computes.Data = append(computes.Data, dwsv1alpha7.ComputesData{Name: nodeName})
 		}
 
 		if t.options.useExternalComputes {
@@ -135,58 +272,26 @@ func (t *T) setup(ctx context.Context, k8sClient client.Client, workflow *dwsv1a
 			Expect(servers.Spec.AllocationSets).To(BeEmpty())
 
 			// Copy the allocation sets from the directive breakdown to the servers resource, assigning servers
-			// as storage resources as necessary.
-
-			// TODO We should assign storage nodes based on the current capabilities of the system and the label. For simple file systems
-			// like XFS and GFS2, we can use any Rabbit. But for Lustre, we have to watch where we land the MDT/MGT, and ensure those are
-			// exclusive to the Rabbit nodes.
-			findStorageServers := func(set *dwsv1alpha7.StorageAllocationSet) []dwsv1alpha7.ServersSpecStorage {
-				switch set.AllocationStrategy {
-				case dwsv1alpha7.AllocatePerCompute:
-					// Make one allocation per compute node
-					storages := make([]dwsv1alpha7.ServersSpecStorage, len(systemConfig.Spec.StorageNodes))
-					for index, node := range systemConfig.Spec.StorageNodes {
-						storages[index].Name = node.Name
-						storages[index].AllocationCount = len(node.ComputesAccess)
-					}
-					return storages
-				case dwsv1alpha7.AllocateAcrossServers:
-					// Make one allocation per Rabbit
-					storages := make([]dwsv1alpha7.ServersSpecStorage, len(systemConfig.Spec.StorageNodes))
-					for index, node := range systemConfig.Spec.StorageNodes {
-						storages[index].Name = node.Name
-						storages[index].AllocationCount = 1
-					}
-					return storages
-				case dwsv1alpha7.AllocateSingleServer:
-					// Make one allocation total
-					storages := make([]dwsv1alpha7.ServersSpecStorage, 1)
-					storages[0].Name = systemConfig.Spec.StorageNodes[rand.Intn(len(systemConfig.Spec.StorageNodes))].Name
-					storages[0].AllocationCount = 1
-					return storages
-				}
-
-				return []dwsv1alpha7.ServersSpecStorage{}
+			// as storage resources as necessary. For simple file systems like XFS and GFS2, we can use any
+			// Rabbit. But for Lustre, MGTs must be exclusive to a Rabbit across the file systems in test;
+			// this is best-effort when the suite is run with parallel processes.
+			placement := serverPlacement{
+				workflow:   workflow.Name,
+				policy:     t.getPlacementPolicy(ctx),
+				rabbits:    nodes.Rabbits,
+				computes:   len(nodes.Computes),
+				mgtRabbits: findMGTRabbits(ctx, k8sClient, servers),
 			}
 
-			servers.Spec.AllocationSets = make([]dwsv1alpha7.ServersSpecAllocationSet, len(directiveBreakdown.Status.Storage.AllocationSets))
-			for index, allocationSet := range directiveBreakdown.Status.Storage.AllocationSets {
-				servers.Spec.AllocationSets[index] = dwsv1alpha7.ServersSpecAllocationSet{
-					AllocationSize: allocationSet.MinimumCapacity,
-					Label:          allocationSet.Label,
-					Storage:        findStorageServers(&allocationSet),
-				}
-			}
+			allocationSets, err := placement.place(ctx, k8sClient, directiveBreakdown.Status.Storage.AllocationSets)
+			Expect(err).NotTo(HaveOccurred(), "directive '%s'", directiveBreakdown.Spec.Directive)
 
-			// TODO: If Lustre - we need to identify the MGT and MDT nodes (and combine them if necessary); and we
-			//       can't colocate MGT nodes with other lustre's that might be in test.
-			//       OST nodes can go anywhere
+			GinkgoWriter.Printf("Placement (%s) for '%s': %s\n", placement.policy, directiveBreakdown.Spec.Directive, placementString(allocationSets))
+			servers.Spec.AllocationSets = allocationSets
 
 			Expect(k8sClient.Update(ctx, servers)).To(Succeed())
 		}
 	}
-
-	t.AdvanceStateAndWaitForReady(ctx, k8sClient, workflow, dwsv1alpha7.StateSetup)
 }
 
 func (t *T) dataIn(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow) {
@@ -199,22 +304,31 @@ func (t *T) preRun(ctx context.Context, k8sClient client.Client, workflow *dwsv1
 
 func (t *T) postRun(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow) {
 	t.AdvanceStateAndWaitForReady(ctx, k8sClient, workflow, dwsv1alpha7.StatePostRun)
-
-	// After successful PostRun, verify MPI container pod logs for SSH errors.
-	// Only MPI workflows use SSH between launcher and workers. Skip for tests
-	// that expect errors since those containers are designed to fail.
-	Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), workflow)).To(Succeed())
-	if _, isMPI := workflow.Status.Env["NNF_CONTAINER_LAUNCHER"]; isMPI && t.options.expectError == nil {
-		VerifyContainerPodLogs(ctx, k8sClient, workflow)
-	}
+	t.verifyState(ctx, k8sClient, workflow, dwsv1alpha7.StatePostRun)
 }
 
 func (t *T) dataOut(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow) {
 	t.AdvanceStateAndWaitForReady(ctx, k8sClient, workflow, dwsv1alpha7.StateDataOut)
+	t.verifyState(ctx, k8sClient, workflow, dwsv1alpha7.StateDataOut)
+}
+
+// verifyState makes the additional checks of a state once the workflow has achieved it
+func (t *T) verifyState(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, state dwsv1alpha7.WorkflowState) {
+	switch state {
+	case dwsv1alpha7.StatePostRun:
+		// After successful PostRun, verify MPI container pod logs for SSH errors.
+		// Only MPI workflows use SSH between launcher and workers. Skip for tests
+		// that expect errors since those containers are designed to fail.
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), workflow)).To(Succeed())
+		if _, isMPI := workflow.Status.Env["NNF_CONTAINER_LAUNCHER"]; isMPI && t.options.expectError == nil {
+			VerifyContainerPodLogs(ctx, k8sClient, workflow)
+		}
 
-	// If copy_out directive was set, verify that the copy_in file matches the copy_out file on global lustre
-	if t.options.globalLustre != nil && len(t.options.globalLustre.out) > 0 {
-		VerifyCopyOut(ctx, k8sClient, t, t.options)
+	case dwsv1alpha7.StateDataOut:
+		// If copy_out directive was set, verify that the copy_in file matches the copy_out file on global lustre
+		if t.options.globalLustre != nil && len(t.options.globalLustre.out) > 0 {
+			VerifyCopyOut(ctx, k8sClient, t, t.options)
+		}
 	}
 }
 
@@ -223,23 +337,77 @@ func (t *T) teardown(ctx context.Context, k8sClient client.Client, workflow *dws
 }
 
 func (t *T) AdvanceStateAndWaitForReady(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, state dwsv1alpha7.WorkflowState) {
+	t.waitForState(ctx, k8sClient, workflow, t.advanceState(ctx, k8sClient, workflow, state))
+}
+
+// stateAdvance is a workflow's transition to a state that has been requested but not yet achieved
+type stateAdvance struct {
+	state     dwsv1alpha7.WorkflowState
+	transient *TExpectTransient
+	start     time.Time
+	update    time.Duration
+}
+
+// advanceState sets the workflow's desired state without waiting for the workflow to achieve it,
+// after running the state's before hooks and injecting the faults of any expected transient
+// condition. Pass the result to waitForState().
+func (t *T) advanceState(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, state dwsv1alpha7.WorkflowState) (advance *stateAdvance) {
+	setActiveState(workflow, state)
+	t.runStateHooks(ctx, k8sClient, workflow, state, true)
+
+	// If expecting a transient condition in this state, induce any faults prior to advancing.
+	// waitForState() restores the faults, unless advancing fails.
+	transient := t.options.expectTransient
+	if transient != nil && transient.state != state {
+		transient = nil
+	}
+	if transient != nil {
+		defer func() {
+			if advance == nil {
+				transient.restoreFaults(ctx, k8sClient, t)
+			}
+		}()
+		transient.injectFaults(ctx, k8sClient, t)
+	}
+
 	By(fmt.Sprintf("Advances to %s State", state))
 
 	// Set the desired State
+	start := time.Now()
 	Eventually(func() error {
 		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), workflow)).Should(Succeed())
 		workflow.Spec.DesiredState = state
 		return k8sClient.Update(ctx, workflow)
 	}).Should(Succeed(), fmt.Sprintf("updates state to '%s'", state))
+	update := time.Since(start)
+
+	t.checkRejectedTransitions(ctx, k8sClient, workflow, state, true)
+
+	return &stateAdvance{state: state, transient: transient, start: start, update: update}
+}
+
+// waitForState waits for the workflow to achieve the state it was advanced to, or the expected
+// error or transient condition in that state, and then runs the state's after hooks.
+func (t *T) waitForState(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, advance *stateAdvance) {
+	state, transient := advance.state, advance.transient
+	if transient != nil {
+		defer transient.restoreFaults(ctx, k8sClient, t)
+	}
 
-	// If expecting an Error in this state, check for that instead
 	if t.options.expectError != nil && t.options.expectError.state == state {
+		// If expecting an Error in this state, check for that instead
 		By("Waiting for Error status")
-		waitForError(ctx, k8sClient, workflow, state)
-		return
+		waitForError(ctx, k8sClient, workflow, t.options.expectError, t.getErrorTimeout(ctx, state))
+	} else if transient != nil {
+		// If expecting a transient condition in this state, check for that and the recovery
+		t.waitForTransientAndRecovery(ctx, k8sClient, workflow, transient)
+	} else {
+		waitForReady(ctx, k8sClient, workflow, state, t.getTimeout(ctx, state))
 	}
 
-	waitForReady(ctx, k8sClient, workflow, state)
+	t.recordTransition(workflow, state, advance.start.Add(advance.update), advance.update)
+	t.checkRejectedTransitions(ctx, k8sClient, workflow, state, false)
+	t.runStateHooks(ctx, k8sClient, workflow, state, false)
 }
 
 // Timeouts can be one of two configurable values passed into the context: lowTimeout and
@@ -272,7 +440,53 @@ func getTimeout(ctx context.Context, state dwsv1alpha7.WorkflowState) time.Durat
 	return t
 }
 
-func waitForReady(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, state dwsv1alpha7.WorkflowState) {
+// getTimeout returns the timeout for the state, taking any per-test timeouts into account. A
+// timeout set for the specific state with WithStateTimeout() takes precedence, followed by the
+// test's low/high timeouts from WithTimeouts(), and finally the suite-wide timeouts. A per-test
+// timeout only ever extends the suite-wide timeout, so a suite run with a larger LTIMEOUT or
+// HTIMEOUT on a slow system is not capped by the test.
+func (t *T) getTimeout(ctx context.Context, state dwsv1alpha7.WorkflowState) time.Duration {
+	o := t.options
+
+	highTimeout, ok := ctx.Value("highTimeout").(time.Duration)
+	if !ok {
+		panic("could not retrieve highTimeout from context")
+	}
+
+	if timeout, found := o.stateTimeouts[state]; found {
+		return max(timeout, getTimeout(ctx, state))
+	}
+
+	if o.lowTimeout == 0 && o.highTimeout == 0 && len(o.highTimeoutStates) == 0 {
+		return getTimeout(ctx, state)
+	}
+
+	highTimeoutStates := o.highTimeoutStates
+	if len(highTimeoutStates) == 0 {
+		highTimeoutStates, _ = ctx.Value("highTimeoutStates").([]dwsv1alpha7.WorkflowState)
+	}
+
+	if slices.Contains(highTimeoutStates, state) {
+		return max(o.highTimeout, highTimeout)
+	}
+
+	// A suite high timeout state that the test does not list keeps the suite's high timeout
+	return max(o.lowTimeout, getTimeout(ctx, state))
+}
+
+// getErrorTimeout returns how long to wait for an expected error in the state. Errors are
+// expected to occur quickly so this is a minute, unless the test overrides its timeouts.
+func (t *T) getErrorTimeout(ctx context.Context, state dwsv1alpha7.WorkflowState) time.Duration {
+	o := t.options
+
+	if _, found := o.stateTimeouts[state]; found || o.lowTimeout != 0 || o.highTimeout != 0 {
+		return t.getTimeout(ctx, state)
+	}
+
+	return time.Minute
+}
+
+func waitForReady(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, state dwsv1alpha7.WorkflowState, timeout time.Duration) {
 
 	achieveState := func(state dwsv1alpha7.WorkflowState) OmegaMatcher {
 		return And(
@@ -282,9 +496,6 @@ func waitForReady(ctx context.Context, k8sClient client.Client, workflow *dwsv1a
 		)
 	}
 
-	// Get the timeout based on which state it is
-	timeout := getTimeout(ctx, state)
-
 	Eventually(func() dwsv1alpha7.WorkflowStatus {
 		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), workflow)).Should(Succeed())
 		return workflow.Status
@@ -294,7 +505,12 @@ func waitForReady(ctx context.Context, k8sClient client.Client, workflow *dwsv1a
 		Should(achieveState(state), fmt.Sprintf("achieve state '%s'", state))
 }
 
-func waitForError(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, state dwsv1alpha7.WorkflowState) {
+// waitForError waits for the expected error and then verifies the error is for the expected
+// reason. The reason is checked after the fact so a workflow that fails for the wrong reason is
+// reported as such, rather than as a timeout.
+func waitForError(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, expected *TExpectError, timeout time.Duration) {
+	state := expected.state
+
 	achieveState := func(state dwsv1alpha7.WorkflowState) OmegaMatcher {
 		return And(
 			HaveField("Ready", BeFalse()),
@@ -308,9 +524,15 @@ func waitForError(ctx context.Context, k8sClient client.Client, workflow *dwsv1a
 		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), workflow)).Should(Succeed())
 		return workflow.Status
 	}).
-		WithTimeout(time.Minute).
+		WithTimeout(timeout).
 		WithPolling(time.Second).
 		Should(achieveState(state), fmt.Sprintf("error in state '%s'", state))
+
+	Expect(expected.mismatch(&workflow.Status)).To(BeEmpty(), fmt.Sprintf("error in state '%s' is for the expected reason", state))
+
+	if expected.matcher != nil {
+		Expect(workflow.Status).To(expected.matcher, fmt.Sprintf("error in state '%s' satisfies the matcher", state))
+	}
 }
 
 func ObjectKeyFromObjectReference(r corev1.ObjectReference) types.NamespacedName {
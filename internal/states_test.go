@@ -0,0 +1,139 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+func TestTimeouts(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "lowTimeout", 2*time.Minute)
+	ctx = context.WithValue(ctx, "highTimeout", 5*time.Minute)
+	ctx = context.WithValue(ctx, "highTimeoutStates", []dwsv1alpha7.WorkflowState{dwsv1alpha7.StateSetup, dwsv1alpha7.StateTeardown})
+
+	tests := []struct {
+		test     *T
+		state    dwsv1alpha7.WorkflowState
+		expected time.Duration
+	}{
+		{MakeTest("Suite"), dwsv1alpha7.StateSetup, 5 * time.Minute},
+		{MakeTest("Suite"), dwsv1alpha7.StateDataIn, 2 * time.Minute},
+		{MakeTest("High").WithTimeouts(0, 10*time.Minute), dwsv1alpha7.StateTeardown, 10 * time.Minute},
+		{MakeTest("High").WithTimeouts(0, 10*time.Minute), dwsv1alpha7.StatePreRun, 2 * time.Minute},
+		{MakeTest("States").WithTimeouts(3*time.Minute, 10*time.Minute, dwsv1alpha7.StatePreRun), dwsv1alpha7.StatePreRun, 10 * time.Minute},
+		{MakeTest("States").WithTimeouts(3*time.Minute, 10*time.Minute, dwsv1alpha7.StatePreRun), dwsv1alpha7.StateSetup, 5 * time.Minute},
+		{MakeTest("States").WithTimeouts(3*time.Minute, 10*time.Minute, dwsv1alpha7.StatePreRun), dwsv1alpha7.StateDataIn, 3 * time.Minute},
+		{MakeTest("State").WithTimeouts(0, 10*time.Minute).WithStateTimeout(dwsv1alpha7.StateSetup, 30*time.Minute), dwsv1alpha7.StateSetup, 30 * time.Minute},
+		{MakeTest("State").WithStateTimeout(dwsv1alpha7.StateSetup, 30*time.Minute), dwsv1alpha7.StateTeardown, 5 * time.Minute},
+		{MakeTest("Shorter").WithTimeouts(time.Minute, 3*time.Minute), dwsv1alpha7.StateSetup, 5 * time.Minute},
+		{MakeTest("Shorter").WithTimeouts(time.Minute, 3*time.Minute), dwsv1alpha7.StateDataIn, 2 * time.Minute},
+		{MakeTest("Shorter").WithStateTimeout(dwsv1alpha7.StateTeardown, time.Minute), dwsv1alpha7.StateTeardown, 5 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if timeout := test.test.getTimeout(ctx, test.state); timeout != test.expected {
+			t.Errorf("%s: expected %v timeout in state %s, got %v", test.test.Name(), test.expected, test.state, timeout)
+		}
+	}
+
+	if timeout := MakeTest("Error").getErrorTimeout(ctx, dwsv1alpha7.StatePreRun); timeout != time.Minute {
+		t.Errorf("expected default error timeout, got %v", timeout)
+	}
+	if timeout := MakeTest("Error").WithStateTimeout(dwsv1alpha7.StatePreRun, 3*time.Minute).getErrorTimeout(ctx, dwsv1alpha7.StatePreRun); timeout != 3*time.Minute {
+		t.Errorf("expected overridden error timeout, got %v", timeout)
+	}
+}
+
+func TestExpectErrorReason(t *testing.T) {
+	test := MakeTest("Error", "#DW jobdw type=xfs name=error capacity=50GB").
+		ExpectError(dwsv1alpha7.StateSetup).
+		WithErrorMessage("(?i)insufficient").
+		WithErrorDriver("^nnf$").
+		WithErrorType("User")
+
+	status := &dwsv1alpha7.WorkflowStatus{
+		Message: "DW Directive 0: Insufficient capacity",
+		Drivers: []dwsv1alpha7.WorkflowDriverStatus{
+			{DriverID: "tc-dws", WatchState: dwsv1alpha7.StateSetup, Status: dwsv1alpha7.StatusCompleted},
+			{DriverID: "nnf", WatchState: dwsv1alpha7.StateSetup, Status: dwsv1alpha7.StatusError, Error: "user error: insufficient capacity"},
+		},
+	}
+
+	expected := test.options.expectError
+	if mismatch := expected.mismatch(status); mismatch != "" {
+		t.Errorf("unexpected mismatch: %s", mismatch)
+	}
+
+	// An error for the wrong reason, e.g. a webhook bug rather than the intended user error
+	status.Drivers[1].Error = "internal error: webhook failed"
+	if mismatch := expected.mismatch(status); mismatch == "" {
+		t.Errorf("expected a mismatch for the wrong error type")
+	}
+
+	status.Message = "DW Directive 0: webhook failed"
+	if mismatch := expected.mismatch(status); !strings.Contains(mismatch, "message") {
+		t.Errorf("expected a message mismatch, found '%s'", mismatch)
+	}
+}
+
+func TestStateTable(t *testing.T) {
+	states := func(test *T) []dwsv1alpha7.WorkflowState {
+		sequence := make([]dwsv1alpha7.WorkflowState, 0)
+		for _, state := range test.States() {
+			sequence = append(sequence, state.State)
+		}
+		return sequence
+	}
+
+	test := MakeTest("States", "#DW jobdw type=xfs name=states capacity=50GB")
+	if !reflect.DeepEqual(states(test), DefaultStates) {
+		t.Errorf("unexpected default states %v", states(test))
+	}
+
+	test.SkipStates(dwsv1alpha7.StateDataIn, dwsv1alpha7.StateDataOut)
+	expected := []dwsv1alpha7.WorkflowState{dwsv1alpha7.StateProposal, dwsv1alpha7.StateSetup, dwsv1alpha7.StatePreRun, dwsv1alpha7.StatePostRun, dwsv1alpha7.StateTeardown}
+	if !reflect.DeepEqual(states(test), expected) {
+		t.Errorf("unexpected states %v, expected %v", states(test), expected)
+	}
+
+	// Jump from PreRun straight to Teardown, repeating the PreRun transition
+	test.WithStateSequence(dwsv1alpha7.StateProposal, dwsv1alpha7.StateSetup, dwsv1alpha7.StatePreRun, dwsv1alpha7.StatePreRun, dwsv1alpha7.StateTeardown)
+	if len(test.States()) != 5 || test.States()[3].State != dwsv1alpha7.StatePreRun {
+		t.Errorf("unexpected states %v", states(test))
+	}
+
+	dup := test.duplicate("-1")
+	if !reflect.DeepEqual(states(dup), states(test)) {
+		t.Errorf("states not duplicated: %v", states(dup))
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for an unknown state")
+		}
+	}()
+	test.WithStateSequence("Unknown")
+}
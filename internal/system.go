@@ -21,36 +21,227 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/ginkgo/v2"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
 )
 
 const (
 	TriageNamespaceName = "nnf-system-needs-triage"
+
+	// TriageConfigMapName is the ConfigMap in the triage namespace holding the triage records
+	TriageConfigMapName = "triage-records"
+
+	// RunIDEnvVar selects the run ID when the `-run-id` flag is not provided
+	RunIDEnvVar = "NNF_TEST_RUN_ID"
 )
 
+// TriageRecord describes a failure that left the system in need of triage
+type TriageRecord struct {
+	Test     string    `json:"test"`
+	Workflow string    `json:"workflow,omitempty"`
+	State    string    `json:"state,omitempty"`
+	Failure  string    `json:"failure"`
+	Time     time.Time `json:"time"`
+	RunID    string    `json:"runID,omitempty"`
+	Version  string    `json:"version,omitempty"`
+
+	// Workflows remaining on the system at the time of the failure, with their state
+	Workflows []string `json:"workflows,omitempty"`
+}
+
+func (r TriageRecord) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s: '%s' failed", r.Time.Format(time.RFC3339), r.Test)
+	if r.Workflow != "" {
+		fmt.Fprintf(b, " in state %s of workflow '%s'", r.State, r.Workflow)
+	}
+	fmt.Fprintf(b, " (run %s, version %s)\n", r.RunID, r.Version)
+	fmt.Fprintf(b, "  %s\n", strings.ReplaceAll(strings.TrimSpace(r.Failure), "\n", "\n  "))
+	if len(r.Workflows) != 0 {
+		fmt.Fprintf(b, "  Workflows: %s\n", strings.Join(r.Workflows, ", "))
+	}
+
+	return b.String()
+}
+
+// key returns the ConfigMap key of the record. Keys sort in time order.
+func (r TriageRecord) key() string {
+	return "failure-" + r.Time.UTC().Format("20060102T150405.000000000Z")
+}
+
+// activeState is the state the most recent workflow was advanced to by this process, along with
+// the spec that advanced it. Ginkgo runs the specs of each parallel process one at a time, so
+// this is the state of the current spec unless the spec has yet to advance a workflow.
+var activeState struct {
+	lock     sync.Mutex
+	spec     string
+	workflow string
+	state    dwsv1alpha7.WorkflowState
+}
+
+func setActiveState(workflow *dwsv1alpha7.Workflow, state dwsv1alpha7.WorkflowState) {
+	activeState.lock.Lock()
+	defer activeState.lock.Unlock()
+
+	activeState.spec = CurrentSpecReport().FullText()
+	activeState.workflow = workflow.Namespace + "/" + workflow.Name
+	activeState.state = state
+}
+
+// NewTriageRecord returns the triage record for a failure of the current spec. The workflow and
+// state are only recorded if the current spec advanced a workflow, so a failure before then (e.g.
+// in Prepare()) does not name the workflow of a previous spec.
+func NewTriageRecord(ctx context.Context, k8sClient client.Client, message, runID string) TriageRecord {
+	record := TriageRecord{
+		Test:    CurrentSpecReport().FullText(),
+		Failure: message,
+		Time:    time.Now(),
+		RunID:   runID,
+	}
+
+	activeState.lock.Lock()
+	if activeState.spec == record.Test {
+		record.Workflow, record.State = activeState.workflow, string(activeState.state)
+	}
+	activeState.lock.Unlock()
+
+	if version, err := GetVersion(); err == nil {
+		record.Version = version
+	}
+
+	workflows := &dwsv1alpha7.WorkflowList{}
+	if err := k8sClient.List(ctx, workflows); err == nil {
+		for _, workflow := range workflows.Items {
+			record.Workflows = append(record.Workflows, fmt.Sprintf("%s/%s (%s)", workflow.Namespace, workflow.Name, workflow.Status.State))
+		}
+	}
+
+	return record
+}
+
+// IsSystemInNeedOfTriage returns true if a previous run left the system in need of triage, and
+// prints the triage records describing why
 func IsSystemInNeedOfTriage(ctx context.Context, k8sClient client.Client) bool {
 
 	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: TriageNamespaceName}}
 	err := k8sClient.Get(ctx, client.ObjectKeyFromObject(ns), ns)
+	if errors.IsNotFound(err) {
+		return false
+	}
 
-	return !errors.IsNotFound(err)
+	records, err := GetTriageRecords(ctx, k8sClient)
+	if err != nil {
+		fmt.Printf("Failed to read the triage records: %v\n", err)
+	} else {
+		fmt.Print(TriageSummary(records))
+	}
+
+	return true
 }
 
-func SetSystemInNeedOfTriage(ctx context.Context, k8sClient client.Client) error {
+// SetSystemInNeedOfTriage creates the triage namespace, preventing further runs until it is
+// deleted, and adds the records to the triage ConfigMap in the namespace
+func SetSystemInNeedOfTriage(ctx context.Context, k8sClient client.Client, records ...TriageRecord) error {
 
 	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: TriageNamespaceName}}
 	if err := k8sClient.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
 		return err
 	}
 
-	return nil
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Parallel processes may fail at the same time
+	return retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return errors.IsConflict(err) || errors.IsAlreadyExists(err)
+	}, func() error {
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: TriageConfigMapName, Namespace: TriageNamespaceName}}
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(configMap), configMap)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		found := err == nil
+
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+
+		for _, record := range records {
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			configMap.Data[record.key()] = string(data)
+		}
+
+		if found {
+			return k8sClient.Update(ctx, configMap)
+		}
+		return k8sClient.Create(ctx, configMap)
+	})
+}
+
+// GetTriageRecords returns the triage records in time order
+func GetTriageRecords(ctx context.Context, k8sClient client.Client) ([]TriageRecord, error) {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: TriageConfigMapName, Namespace: TriageNamespaceName}}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(configMap), configMap); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return parseTriageRecords(configMap.Data)
+}
+
+func parseTriageRecords(data map[string]string) ([]TriageRecord, error) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	records := make([]TriageRecord, 0, len(keys))
+	for _, key := range keys {
+		record := TriageRecord{}
+		if err := json.Unmarshal([]byte(data[key]), &record); err != nil {
+			return nil, fmt.Errorf("triage record '%s': %w", key, err)
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// TriageSummary formats the triage records for printing
+func TriageSummary(records []TriageRecord) string {
+	if len(records) == 0 {
+		return fmt.Sprintf("The '%s' namespace has no triage records\n", TriageNamespaceName)
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%d failure(s) require triage:\n", len(records))
+	for _, record := range records {
+		b.WriteString(record.String())
+	}
+
+	return b.String()
 }
 
 // IsSystemReserved checks if the system under test is reserved by a known developer.
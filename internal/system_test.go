@@ -20,7 +20,10 @@
 package internal
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -59,3 +62,49 @@ func TestAuthorizedDevelopers(t *testing.T) {
 	}
 
 }
+
+func TestTriageRecords(t *testing.T) {
+	first := TriageRecord{
+		Test:      "XFS Executes",
+		Workflow:  "default/xfs",
+		State:     "Setup",
+		Failure:   "Timed out after 300s.\nExpected workflow to be ready",
+		Time:      time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+		RunID:     "seed-1",
+		Version:   "v0.1.2",
+		Workflows: []string{"default/xfs (Setup)"},
+	}
+	second := first
+	second.Test = "Lustre Executes"
+	second.Time = first.Time.Add(time.Millisecond)
+
+	data := make(map[string]string)
+	for _, record := range []TriageRecord{second, first} {
+		value, err := json.Marshal(record)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		data[record.key()] = string(value)
+	}
+
+	records, err := parseTriageRecords(data)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if len(records) != 2 || records[0].Test != first.Test || records[1].Test != second.Test {
+		t.Fatalf("expected records in time order, got %v", records)
+	}
+
+	summary := TriageSummary(records)
+	for _, expected := range []string{
+		"2 failure(s) require triage",
+		"'XFS Executes' failed in state Setup of workflow 'default/xfs' (run seed-1, version v0.1.2)",
+		"  Expected workflow to be ready",
+		"Workflows: default/xfs (Setup)",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("expected '%s' in summary:\n%s", expected, summary)
+		}
+	}
+}
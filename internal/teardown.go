@@ -0,0 +1,139 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+	nnfv1alpha11 "github.com/NearNodeFlash/nnf-sos/api/v1alpha11"
+)
+
+// AbortStates are the intermediate states a workflow can be aborted from
+var AbortStates = []dwsv1alpha7.WorkflowState{
+	dwsv1alpha7.StateProposal,
+	dwsv1alpha7.StateSetup,
+	dwsv1alpha7.StateDataIn,
+	dwsv1alpha7.StatePreRun,
+	dwsv1alpha7.StatePostRun,
+	dwsv1alpha7.StateDataOut,
+}
+
+// AbortDimension returns a matrix dimension that aborts the test after each intermediate state.
+// For example,
+//
+//	MakeMatrix("Abort", "#DW jobdw type=xfs name=abort capacity=50GB").Across(AbortDimension()).Tests()
+func AbortDimension() MatrixDimension {
+	values := make([]MatrixValue, len(AbortStates))
+	for index, state := range AbortStates {
+		values[index] = MatrixValue{
+			Name:  string(state),
+			Apply: func(t *T) *T { return t.AbortAfter(state) },
+		}
+	}
+
+	return Variants("abort", values...)
+}
+
+// AbortInEachState returns a copy of the test for each intermediate state that aborts the
+// workflow after the state, e.g.
+//
+//	AbortInEachState(MakeTest("XFS", "#DW jobdw type=xfs name=xfs capacity=50GB"))...
+func AbortInEachState(t *T) []*T {
+	tests := make([]*T, len(AbortStates))
+	for index, state := range AbortStates {
+		tests[index] = t.duplicate("-abort-" + matrixName(string(state))).AbortAfter(state)
+	}
+
+	return tests
+}
+
+// EnsureTeardown advances the workflow to Teardown unless it has already achieved Teardown, such
+// as after AbortAfter() or a table of states that ends in Teardown. This keeps the Teardown state
+// hooks and rejected transition checks from running a second time on cleanup.
+func (t *T) EnsureTeardown(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow) {
+	Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), workflow)).To(Succeed())
+	if workflow.Status.State == dwsv1alpha7.StateTeardown && workflow.Status.Ready {
+		return
+	}
+
+	t.AdvanceStateAndWaitForReady(ctx, k8sClient, workflow, dwsv1alpha7.StateTeardown)
+}
+
+// VerifyCleanTeardown verifies the resources created for the workflow are gone after teardown;
+// i.e. the NnfStorages, NnfAccesses, ClientMounts and container pods. Storage created for a
+// persistent storage instance outlives the workflow and is not checked.
+func VerifyCleanTeardown(ctx context.Context, k8sClient client.Client, t *T, workflow *dwsv1alpha7.Workflow) {
+	By("Verifying a clean teardown")
+
+	matchingWorkflow := client.MatchingLabels{
+		dwsv1alpha7.WorkflowNameLabel:      workflow.Name,
+		dwsv1alpha7.WorkflowNamespaceLabel: workflow.Namespace,
+	}
+
+	remaining := func() []string {
+		names := make([]string, 0)
+
+		storages := &nnfv1alpha11.NnfStorageList{}
+		Expect(k8sClient.List(ctx, storages, matchingWorkflow)).To(Succeed())
+		for _, storage := range storages.Items {
+			if storage.Labels[dwsv1alpha7.OwnerKindLabel] != "PersistentStorageInstance" {
+				names = append(names, "NnfStorage "+client.ObjectKeyFromObject(&storage).String())
+			}
+		}
+
+		accesses := &nnfv1alpha11.NnfAccessList{}
+		Expect(k8sClient.List(ctx, accesses, matchingWorkflow)).To(Succeed())
+		for _, access := range accesses.Items {
+			names = append(names, "NnfAccess "+client.ObjectKeyFromObject(&access).String())
+		}
+
+		clientMounts := &dwsv1alpha7.ClientMountList{}
+		Expect(k8sClient.List(ctx, clientMounts, matchingWorkflow)).To(Succeed())
+		for _, clientMount := range clientMounts.Items {
+			names = append(names, "ClientMount "+client.ObjectKeyFromObject(&clientMount).String())
+		}
+
+		pods := &corev1.PodList{}
+		Expect(k8sClient.List(ctx, pods, client.InNamespace(workflow.Namespace), matchingWorkflow, client.HasLabels{nnfv1alpha11.ContainerLabel})).To(Succeed())
+		for _, pod := range pods.Items {
+			names = append(names, "Pod "+client.ObjectKeyFromObject(&pod).String())
+		}
+
+		return names
+	}
+
+	Eventually(remaining).
+		WithTimeout(t.getTimeout(ctx, dwsv1alpha7.StateTeardown)).
+		WithPolling(time.Second).
+		Should(BeEmpty(), func() string {
+			return fmt.Sprintf("resources remain after teardown of workflow '%s': %s", workflow.Name, strings.Join(remaining(), ", "))
+		})
+}
@@ -0,0 +1,281 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.openly.dev/pointy"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+// TransientFault induces an outage that a workflow is expected to recover from. Inject is called
+// before the workflow advances to the state and Restore once the workflow reports the transient
+// condition.
+type TransientFault interface {
+	Inject(ctx context.Context, k8sClient client.Client, t *T) error
+	Restore(ctx context.Context, k8sClient client.Client, t *T) error
+
+	// StopsController returns true if the fault stops the controller that would report the
+	// TransientCondition, in which case the workflow waiting in DriverWait is the expected signal.
+	StopsController() bool
+
+	// Clone returns an independent copy of the fault for a copy of the test (e.g. DuplicateTest() or
+	// a soak iteration). Names maps the objects managed by the original test to the names of those
+	// of the copy, so a fault targeting one of them targets the copy's object instead.
+	Clone(names map[string]string) TransientFault
+
+	String() string
+}
+
+// ReplicasAnnotation records the replica count of a deployment scaled down by a fault, so that
+// the count is restored even when more than one process scales the deployment down at once.
+const ReplicasAnnotation = "nnf-integration-test/replicas"
+
+type scaleDeploymentFault struct {
+	key      types.NamespacedName
+	replicas int32
+}
+
+// ScaleDeploymentFault scales a controller's deployment down to zero replicas, and back up to its
+// original replica count on restore (e.g. the nnf-sos controller manager). The stopped controller
+// cannot report a TransientCondition, so the workflow is expected to wait in DriverWait instead.
+func ScaleDeploymentFault(namespace, name string) TransientFault {
+	return &scaleDeploymentFault{key: types.NamespacedName{Namespace: namespace, Name: name}}
+}
+
+func (f *scaleDeploymentFault) Inject(ctx context.Context, k8sClient client.Client, t *T) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deployment := &appsv1.Deployment{}
+		if err := k8sClient.Get(ctx, f.key, deployment); err != nil {
+			return err
+		}
+
+		// The original count is recorded by whichever fault scaled the deployment down first
+		replicas := pointy.Int32Value(deployment.Spec.Replicas, 1)
+		if value, found := deployment.Annotations[ReplicasAnnotation]; found {
+			original, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				return fmt.Errorf("deployment '%s' annotation %s: %w", f.key, ReplicasAnnotation, err)
+			}
+			replicas = int32(original)
+		} else {
+			if deployment.Annotations == nil {
+				deployment.Annotations = make(map[string]string)
+			}
+			deployment.Annotations[ReplicasAnnotation] = strconv.Itoa(int(replicas))
+		}
+
+		f.replicas = replicas
+		deployment.Spec.Replicas = pointy.Int32(0)
+
+		return k8sClient.Update(ctx, deployment)
+	})
+}
+
+func (f *scaleDeploymentFault) Restore(ctx context.Context, k8sClient client.Client, t *T) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deployment := &appsv1.Deployment{}
+		if err := k8sClient.Get(ctx, f.key, deployment); err != nil {
+			return err
+		}
+
+		delete(deployment.Annotations, ReplicasAnnotation)
+		deployment.Spec.Replicas = pointy.Int32(f.replicas)
+
+		return k8sClient.Update(ctx, deployment)
+	})
+}
+
+func (f *scaleDeploymentFault) StopsController() bool {
+	return true
+}
+
+func (f *scaleDeploymentFault) Clone(names map[string]string) TransientFault {
+	return &scaleDeploymentFault{key: f.key}
+}
+
+func (f *scaleDeploymentFault) String() string {
+	return fmt.Sprintf("scale deployment '%s' to zero", f.key)
+}
+
+type deleteObjectFault struct {
+	object client.Object
+	saved  client.Object
+}
+
+// DeleteObjectFault deletes the object (e.g. a profile), and recreates it from the copy read
+// prior to deletion on restore.
+func DeleteObjectFault(object client.Object) TransientFault {
+	return &deleteObjectFault{object: object}
+}
+
+func (f *deleteObjectFault) Inject(ctx context.Context, k8sClient client.Client, t *T) error {
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(f.object), f.object); err != nil {
+		return err
+	}
+
+	f.saved = f.object.DeepCopyObject().(client.Object)
+
+	return k8sClient.Delete(ctx, f.object)
+}
+
+func (f *deleteObjectFault) Restore(ctx context.Context, k8sClient client.Client, t *T) error {
+	WaitForDeletion(ctx, k8sClient, f.object)
+
+	object := f.saved.DeepCopyObject().(client.Object)
+	object.SetResourceVersion("")
+	object.SetUID("")
+
+	return k8sClient.Create(ctx, object)
+}
+
+func (f *deleteObjectFault) StopsController() bool {
+	return false
+}
+
+func (f *deleteObjectFault) Clone(names map[string]string) TransientFault {
+	object := f.object.DeepCopyObject().(client.Object)
+	if name, found := names[object.GetName()]; found {
+		object.SetName(name)
+	}
+	object.SetResourceVersion("")
+	object.SetUID("")
+
+	return &deleteObjectFault{object: object}
+}
+
+func (f *deleteObjectFault) String() string {
+	return fmt.Sprintf("delete '%s'", client.ObjectKeyFromObject(f.object))
+}
+
+// transientDriverWaitPersistence is how long a workflow must wait in DriverWait, with a fault
+// that stops a controller injected, before it is considered to be in the transient condition.
+const transientDriverWaitPersistence = 30 * time.Second
+
+// stopsController returns true if any of the faults stops a controller
+func (e *TExpectTransient) stopsController() bool {
+	for _, fault := range e.faults {
+		if fault.StopsController() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// transientWatch follows the workflow's status while waiting for the transient condition
+type transientWatch struct {
+	state dwsv1alpha7.WorkflowState
+
+	// DriverWait that persists is accepted as the transient condition
+	driverWait      bool
+	driverWaitSince time.Time
+}
+
+// observe returns true once the status shows the transient condition; a TransientCondition, or
+// DriverWait persisting for transientDriverWaitPersistence when a fault stops a controller. An
+// error is returned if the workflow completes the state without it, as the faults had no effect.
+func (w *transientWatch) observe(status *dwsv1alpha7.WorkflowStatus, now time.Time) (bool, error) {
+	if status.State != w.state {
+		w.driverWaitSince = time.Time{}
+		return false, nil
+	}
+
+	switch {
+	case status.Ready && status.Status == dwsv1alpha7.StatusCompleted:
+		return false, fmt.Errorf("completed state '%s' without reporting a transient condition", w.state)
+	case status.Status == dwsv1alpha7.StatusTransientCondition:
+		return true, nil
+	case status.Status == dwsv1alpha7.StatusDriverWait && w.driverWait:
+		if w.driverWaitSince.IsZero() {
+			w.driverWaitSince = now
+		}
+		return now.Sub(w.driverWaitSince) >= transientDriverWaitPersistence, nil
+	}
+
+	w.driverWaitSince = time.Time{}
+	return false, nil
+}
+
+// injectFaults induces the outages for the expected transient condition
+func (e *TExpectTransient) injectFaults(ctx context.Context, k8sClient client.Client, t *T) {
+	for _, fault := range e.faults {
+		By(fmt.Sprintf("Injecting fault: %s", fault))
+		e.injected = append(e.injected, fault)
+		Expect(fault.Inject(ctx, k8sClient, t)).To(Succeed())
+	}
+}
+
+// restoreFaults restores any injected faults. It is safe to call more than once so that it can
+// be deferred to ensure the system is not left in an outage if the test fails.
+func (e *TExpectTransient) restoreFaults(ctx context.Context, k8sClient client.Client, t *T) {
+	injected := e.injected
+	e.injected = nil
+
+	for _, fault := range injected {
+		By(fmt.Sprintf("Restoring fault: %s", fault))
+		Expect(fault.Restore(ctx, k8sClient, t)).To(Succeed())
+	}
+}
+
+// waitForTransientAndRecovery waits for the workflow to report the transient condition in the
+// state, restores any faults, and then waits for the workflow to recover within the budget.
+// DriverWait is the normal in-progress status of every state, so it only counts when a fault stops
+// the controller that would otherwise report a TransientCondition, and only once it persists. A
+// workflow that completes the state first fails immediately, as the faults had no effect.
+func (t *T) waitForTransientAndRecovery(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, expected *TExpectTransient) {
+	state := expected.state
+	watch := &transientWatch{state: state, driverWait: expected.stopsController()}
+
+	By("Waiting for a transient condition")
+	Eventually(func() bool {
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), workflow)).Should(Succeed())
+		found, err := watch.observe(&workflow.Status, time.Now())
+		if err != nil {
+			StopTrying(fmt.Sprintf("workflow '%s' %v", workflow.Name, err)).Now()
+		}
+		return found
+	}).
+		WithTimeout(t.getTimeout(ctx, state)).
+		WithPolling(time.Second).
+		Should(BeTrue(), func() string {
+			return fmt.Sprintf("transient condition in state '%s': %+v", state, workflow.Status)
+		})
+
+	GinkgoWriter.Printf("Workflow '%s' reported '%s' in state '%s': %s\n", workflow.Name, workflow.Status.Status, state, workflow.Status.Message)
+
+	expected.restoreFaults(ctx, k8sClient, t)
+
+	By(fmt.Sprintf("Waiting for recovery within %v", expected.budget))
+	waitForReady(ctx, k8sClient, workflow, state, expected.budget)
+}
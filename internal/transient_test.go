@@ -0,0 +1,87 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+	nnfv1alpha11 "github.com/NearNodeFlash/nnf-sos/api/v1alpha11"
+)
+
+func TestTransientWatch(t *testing.T) {
+	start := time.Now()
+	status := func(state dwsv1alpha7.WorkflowState, s string, ready bool) *dwsv1alpha7.WorkflowStatus {
+		return &dwsv1alpha7.WorkflowStatus{State: state, Status: s, Ready: ready}
+	}
+
+	// DriverWait is the normal in-progress status, so it only counts for a stopped controller
+	watch := &transientWatch{state: dwsv1alpha7.StateSetup}
+	if found, _ := watch.observe(status(dwsv1alpha7.StateSetup, dwsv1alpha7.StatusDriverWait, false), start.Add(time.Hour)); found {
+		t.Errorf("expected DriverWait not to be a transient condition")
+	}
+	if found, _ := watch.observe(status(dwsv1alpha7.StateSetup, dwsv1alpha7.StatusTransientCondition, false), start); !found {
+		t.Errorf("expected a TransientCondition")
+	}
+	if _, err := watch.observe(status(dwsv1alpha7.StateSetup, dwsv1alpha7.StatusCompleted, true), start); err == nil {
+		t.Errorf("expected an error for a state completed without a transient condition")
+	}
+
+	// With the controller stopped, DriverWait must persist
+	watch = &transientWatch{state: dwsv1alpha7.StateSetup, driverWait: true}
+	if found, _ := watch.observe(status(dwsv1alpha7.StateProposal, dwsv1alpha7.StatusCompleted, true), start); found {
+		t.Errorf("expected the previous state not to count")
+	}
+	if found, _ := watch.observe(status(dwsv1alpha7.StateSetup, dwsv1alpha7.StatusDriverWait, false), start); found {
+		t.Errorf("expected DriverWait to have to persist")
+	}
+	if found, _ := watch.observe(status(dwsv1alpha7.StateSetup, dwsv1alpha7.StatusDriverWait, false), start.Add(transientDriverWaitPersistence)); !found {
+		t.Errorf("expected a persistent DriverWait to be a transient condition")
+	}
+}
+
+func TestCloneTransientFaults(t *testing.T) {
+	profile := &nnfv1alpha11.NnfStorageProfile{ObjectMeta: metav1.ObjectMeta{Name: "outage-profile", Namespace: "nnf-system"}}
+	test := MakeTest("Outage", "#DW jobdw type=xfs name=outage capacity=50GB profile=outage-profile").
+		WithStorageProfile().
+		ExpectTransient(dwsv1alpha7.StateSetup, 5*time.Minute, ScaleDeploymentFault("nnf-system", "nnf-controller-manager"), DeleteObjectFault(profile))
+
+	dup := test.duplicate("-1")
+
+	original, cloned := test.options.expectTransient.faults, dup.options.expectTransient.faults
+	for index := range original {
+		if original[index] == cloned[index] {
+			t.Errorf("fault '%s' is shared with the duplicate", original[index])
+		}
+	}
+
+	if !cloned[0].StopsController() || cloned[1].StopsController() {
+		t.Errorf("unexpected faults stopping a controller")
+	}
+	if cloned[1].String() != "delete 'nnf-system/outage-profile-1'" {
+		t.Errorf("expected the duplicate to delete its own profile, got '%s'", cloned[1])
+	}
+	if original[1].String() != "delete 'nnf-system/outage-profile'" {
+		t.Errorf("original fault modified: '%s'", original[1])
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2026 Hewlett Packard Enterprise Development LP
+ * Other additional copyright holders may be indicated within.
+ *
+ * The entirety of this work is licensed under the Apache License,
+ * Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License.
+ *
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	dwsv1alpha7 "github.com/DataWorkflowServices/dws/api/v1alpha7"
+)
+
+type TExpectTransitionRejected struct {
+	from    dwsv1alpha7.WorkflowState
+	to      dwsv1alpha7.WorkflowState
+	message *regexp.Regexp
+}
+
+// ExpectTransitionRejected attempts to change the workflow's desired state from the state to an
+// illegal state and expects the DWS webhook to reject the update. The rejection error must match
+// the optional regular expression. Going backwards or skipping states is attempted once the
+// workflow is Ready in the from state. Advancing to the next state is only illegal before the
+// workflow is Ready, so it is attempted immediately after the desired state is set to the from
+// state. The test fails if the workflow is Ready before the advance can be attempted, since the
+// rejection was not verified. Teardown is legal from every state and cannot be the to state. For
+// example,
+//
+//	ExpectTransitionRejected(dwsv1alpha7.StatePreRun, dwsv1alpha7.StateSetup, "(?i)state")
+//
+// The workflow continues through its remaining states once the rejection is verified.
+func (t *T) ExpectTransitionRejected(from, to dwsv1alpha7.WorkflowState, message ...string) *T {
+	if !slices.Contains(DefaultStates, from) || !slices.Contains(DefaultStates, to) {
+		panic(fmt.Sprintf("Test '%s' has an unknown state transition '%s' to '%s'", t.name, from, to))
+	}
+	if from == to {
+		panic(fmt.Sprintf("Test '%s' cannot expect a rejected transition from '%s' to itself", t.name, from))
+	}
+	if to == dwsv1alpha7.StateTeardown {
+		panic(fmt.Sprintf("Test '%s' cannot expect a rejected transition from '%s' to '%s'; teardown is always legal", t.name, from, to))
+	}
+	if len(message) > 1 {
+		panic(fmt.Sprintf("Test '%s' option ExpectTransitionRejected accepts a single message", t.name))
+	}
+
+	expected := TExpectTransitionRejected{from: from, to: to}
+	if len(message) != 0 {
+		expected.message = mustCompile(t, "ExpectTransitionRejected", message[0])
+	}
+
+	t.options.rejectedTransitions = append(t.options.rejectedTransitions, expected)
+	return t.WithLabels("transition")
+}
+
+// beforeReady returns true if the transition is only illegal while the from state is not Ready
+func (e *TExpectTransitionRejected) beforeReady() bool {
+	from := slices.Index(DefaultStates, e.from)
+	return from+1 < len(DefaultStates) && DefaultStates[from+1] == e.to
+}
+
+// checkRejectedTransitions attempts the illegal transitions from the state. beforeReady selects
+// the transitions attempted before the workflow is Ready in the state, or those attempted after.
+func (t *T) checkRejectedTransitions(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow, state dwsv1alpha7.WorkflowState, beforeReady bool) {
+	for _, expected := range t.options.rejectedTransitions {
+		if expected.from == state && expected.beforeReady() == beforeReady {
+			expected.verify(ctx, k8sClient, workflow)
+		}
+	}
+}
+
+// verify attempts to update the workflow's desired state and verifies the update is rejected
+// for a reason other than a conflict. A copy of the workflow is updated so the test's workflow
+// is not modified if the update is unexpectedly accepted.
+func (e *TExpectTransitionRejected) verify(ctx context.Context, k8sClient client.Client, workflow *dwsv1alpha7.Workflow) {
+	By(fmt.Sprintf("Verifying transition from %s to %s is rejected", e.from, e.to))
+
+	attempt := &dwsv1alpha7.Workflow{}
+	var err error
+	Eventually(func() bool {
+		err = nil
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workflow), attempt)).To(Succeed())
+		if e.beforeReady() && attempt.Status.Ready {
+			return true
+		}
+
+		attempt.Spec.DesiredState = e.to
+		err = k8sClient.Update(ctx, attempt)
+		return !errors.IsConflict(err)
+	}).Should(BeTrue(), fmt.Sprintf("attempts transition from '%s' to '%s'", e.from, e.to))
+
+	if err == nil && e.beforeReady() && attempt.Status.Ready {
+		message := fmt.Sprintf("Workflow '%s' was Ready in state '%s' before the transition to '%s' could be attempted", workflow.Name, e.from, e.to)
+		AddReportEntry("Transition Not Verified", message)
+		Fail(message)
+	}
+
+	Expect(err).To(HaveOccurred(), fmt.Sprintf("transition from '%s' to '%s' was accepted", e.from, e.to))
+	if e.message != nil {
+		Expect(err.Error()).To(MatchRegexp(e.message.String()), fmt.Sprintf("transition from '%s' to '%s' rejected for the wrong reason", e.from, e.to))
+	}
+
+	GinkgoWriter.Printf("Workflow '%s' transition from '%s' to '%s' rejected: %v\n", workflow.Name, e.from, e.to, err)
+}
+
+// VerifyCreateRejected verifies the creation of the test's workflow is rejected with an error
+// matching ExpectCreateRejected(). A workflow that is unexpectedly created is torn down and
+// deleted before the test fails.
+func (t *T) VerifyCreateRejected(ctx context.Context, k8sClient client.Client) {
+	workflow := t.Workflow()
+	expected := t.options.expectCreateRejected
+
+	err := k8sClient.Create(ctx, workflow)
+	if err == nil {
+		t.EnsureTeardown(ctx, k8sClient, workflow)
+		Expect(k8sClient.Delete(ctx, workflow)).To(Succeed())
+		WaitForDeletion(ctx, k8sClient, workflow)
+	}
+
+	Expect(err).To(HaveOccurred(), fmt.Sprintf("workflow '%s' was created", workflow.Name))
+	Expect(err.Error()).To(MatchRegexp(expected.String()), fmt.Sprintf("workflow '%s' rejected for the wrong reason", workflow.Name))
+
+	GinkgoWriter.Printf("Workflow '%s' rejected: %v\n", workflow.Name, err)
+}
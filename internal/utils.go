@@ -204,6 +204,25 @@ func VerifyCopyOut(ctx context.Context, k8sClient client.Client, t *T, o TOption
 	})
 }
 
+// Start up a pod that accesses the global lustre filesystem and removes the files
+// specified by the copy_in and copy_out directives, along with any directories
+// created for them that are left empty.
+func CleanupCopyInCopyOut(ctx context.Context, k8sClient client.Client, t *T, o TOptions) {
+	lus := o.globalLustre
+
+	args := []string{lus.mountRoot}
+	for _, path := range []string{lus.in, lus.out} {
+		if len(path) > 0 {
+			// VerifyCopyOut escapes the asterisk for index mount directories; the
+			// cleanup script expects to glob it.
+			args = append(args, strings.ReplaceAll(path, "\\*", "*"))
+		}
+	}
+
+	By("Starting cleanup pod and removing file(s) from global lustre")
+	runHelperPod(ctx, k8sClient, t, "cleanup", "/cleanup.sh", args)
+}
+
 // Start up a pod with the given command/args and verify that it runs to completion
 func runHelperPod(ctx context.Context, k8sClient client.Client, t *T, name, command string, args []string) {
 	systemConfig := GetSystemConfiguraton(ctx, k8sClient)
@@ -253,6 +272,7 @@ func runHelperPod(ctx context.Context, k8sClient client.Client, t *T, name, comm
 
 	Expect(k8sClient.Create(ctx, pod)).To(Succeed())
 	t.helperPods = append(t.helperPods, pod)
+	t.resources.HelperPods++
 
 	// Wait for successful completion. Use a generous timeout to account for
 	// image pulls which can take several minutes on a cold cache.
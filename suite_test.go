@@ -36,6 +36,7 @@ import (
 	"go.uber.org/zap/zapcore"
 	zapcr "sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
@@ -48,6 +49,18 @@ import (
 
 var (
 	ignoreReservation bool
+	catalog           string
+	placement         string
+	soak              string
+	results           string
+	metrics           string
+	artifacts         string
+	runID             string
+
+	baseline            string
+	updateBaseline      bool
+	regressionThreshold float64
+	regressionStrict    bool
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -59,6 +72,17 @@ var (
 
 func init() {
 	flag.BoolVar(&ignoreReservation, "ignore-reservation", false, "Ignore any reservations on the system that might prevent test execution")
+	flag.StringVar(&catalog, "catalog", "", fmt.Sprintf("Comma separated list of test catalog files or directories. Defaults to $%s or '%s'", CatalogEnvVar, DefaultCatalogPath))
+	flag.StringVar(&soak, "soak", "", "Repeat every selected test for an iteration count and/or duration, optionally continuing on failure: e.g. 100, 8h,continue. A duration requires a ginkgo --timeout (default 1h) longer than the duration times the number of selected tests")
+	flag.StringVar(&results, "results", os.Getenv(ResultsEnvVar), fmt.Sprintf("Write the results of the run, including the state timings of each test, as JSON to this file. Defaults to $%s", ResultsEnvVar))
+	flag.StringVar(&metrics, "metrics", os.Getenv(MetricsEnvVar), fmt.Sprintf("Write test results, state duration histograms and resource counts in the Prometheus text format to this file. Defaults to $%s", MetricsEnvVar))
+	flag.StringVar(&artifacts, "artifacts", os.Getenv(ArtifactsEnvVar), fmt.Sprintf("Directory for the diagnostics collected from failed tests. Defaults to $%s or '%s'", ArtifactsEnvVar, DefaultArtifactsPath))
+	flag.StringVar(&runID, "run-id", os.Getenv(RunIDEnvVar), fmt.Sprintf("Identifies the run in the triage records. Defaults to $%s or the random seed, which is shared by parallel processes", RunIDEnvVar))
+	flag.StringVar(&baseline, "baseline", os.Getenv(BaselineEnvVar), fmt.Sprintf("Compare the Setup, PreRun and Teardown latencies of each test against this system's baseline file. Defaults to $%s", BaselineEnvVar))
+	flag.BoolVar(&updateBaseline, "update-baseline", false, "Update the baseline file with the latencies of the passed tests rather than comparing against it")
+	flag.Float64Var(&regressionThreshold, "regression-threshold", 25, "Percentage a state's latency may exceed its baseline before it is reported as a regression")
+	flag.BoolVar(&regressionStrict, "regression-strict", false, "Fail the suite if any state regresses against the baseline")
+	flag.StringVar(&placement, "placement", "", "Server placement policy for all tests: default, round-robin, random[:SEED], capacity, or explicit:LABEL=RABBIT,...[;...]")
 }
 
 func TestEverything(t *testing.T) {
@@ -74,6 +98,9 @@ var _ = BeforeSuite(func() {
 
 	ctx, cancel = context.WithCancel(context.Background())
 
+	// The suite timeout runs from about now; soaks only have what is left of it
+	ctx = context.WithValue(ctx, SuiteStartContextKey, time.Now())
+
 	By("Retrieving Workflow State Timeouts")
 	// given a default timeout and a env var name, get the duration string and parse it
 	getTimeoutDuration := func(d, env string) time.Duration {
@@ -98,6 +125,23 @@ var _ = BeforeSuite(func() {
 	fmt.Printf("Using a low timeout of '%s'\n", lowTimeoutDuration)
 	fmt.Printf("Using a high timeout of '%s' for the following states: %v\n", highTimeoutDuration, highTimeoutStates)
 
+	By("Selecting Placement Policy")
+	placementPolicy, err := ParsePlacementPolicy(placement)
+	Expect(err).NotTo(HaveOccurred())
+	if placementPolicy != nil {
+		ctx = context.WithValue(ctx, PlacementPolicyContextKey, placementPolicy)
+		fmt.Printf("Using the '%s' placement policy\n", placementPolicy)
+	}
+
+	if runID == "" {
+		runID = fmt.Sprintf("seed-%d", GinkgoRandomSeed())
+	}
+
+	if artifacts == "" {
+		artifacts = DefaultArtifactsPath
+	}
+	ctx = context.WithValue(ctx, ArtifactsContextKey, artifacts)
+
 	By("Bootstrapping Test Env")
 	useExistingClustre := true
 	testEnv = &envtest.Environment{UseExistingCluster: &useExistingClustre}
@@ -117,10 +161,16 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 
 	By("Creating Client")
-	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	// A client that can watch is used to record the events of each test
+	k8sClient, err = client.NewWithWatch(cfg, client.Options{Scheme: scheme.Scheme})
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
 
+	// A clientset is used to stream the controller logs of failed tests
+	clientset, err := kubernetes.NewForConfig(cfg)
+	Expect(err).NotTo(HaveOccurred())
+	ctx = context.WithValue(ctx, ClientsetContextKey, kubernetes.Interface(clientset))
+
 	// Check if the system is currently in need of tirage and prevent test execution if so
 	if IsSystemInNeedOfTriage(ctx, k8sClient) {
 		AbortSuite(fmt.Sprintf("System requires triage. Delete the '%s' namespace when finished", TriageNamespaceName))
@@ -150,9 +200,64 @@ var _ = AfterSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 })
 
+// Write the results and metrics of every test, across all parallel processes, once the suite
+// completes, and compare the state latencies against the system's baseline
+var _ = ReportAfterSuite("Results", func(report Report) {
+	if results == "" && metrics == "" && baseline == "" {
+		return
+	}
+
+	runResults, err := BuildResults(report)
+	Expect(err).NotTo(HaveOccurred())
+
+	if results != "" {
+		Expect(WriteResults(results, runResults)).To(Succeed())
+		fmt.Printf("Wrote results to '%s'\n", results)
+	}
+
+	if metrics != "" {
+		Expect(WriteMetrics(metrics, runResults)).To(Succeed())
+		fmt.Printf("Wrote metrics to '%s'\n", metrics)
+	}
+
+	if baseline == "" {
+		return
+	}
+
+	systemBaseline, err := ReadBaseline(baseline)
+	Expect(err).NotTo(HaveOccurred())
+
+	// The latencies of one system say nothing about another, so neither compare nor update them
+	system, _ := CurrentContext()
+	Expect(systemBaseline.CheckSystem(system)).To(Succeed(), fmt.Sprintf("baseline '%s'", baseline))
+
+	if updateBaseline {
+		if systemBaseline.System == "" {
+			systemBaseline.System = system
+		}
+
+		systemBaseline.Update(runResults, BaselineStates)
+		Expect(WriteBaseline(baseline, systemBaseline)).To(Succeed())
+		fmt.Printf("Updated baseline '%s'\n", baseline)
+		return
+	}
+
+	regressions := systemBaseline.Compare(runResults, BaselineStates, regressionThreshold)
+	if len(regressions) == 0 {
+		return
+	}
+
+	summary := RegressionSummary(regressions, regressionThreshold)
+	if regressionStrict {
+		Fail(summary)
+	}
+
+	fmt.Print(summary)
+})
+
 func FailHandler(message string, callerSkip ...int) {
 	if ctx != nil && k8sClient != nil {
-		if err := SetSystemInNeedOfTriage(ctx, k8sClient); err != nil {
+		if err := SetSystemInNeedOfTriage(ctx, k8sClient, NewTriageRecord(ctx, k8sClient, message, runID)); err != nil {
 			log.Log.Error(err, "Failed to configure the system for triage")
 		}
 	}